@@ -16,9 +16,13 @@ package cmd
 
 import (
 	"fmt"
+	"net"
+	"strconv"
+	"strings"
 
 	"github.com/jeffwubj/kubev/pkg/kubev/constants"
 	"github.com/jeffwubj/kubev/pkg/kubev/deployer"
+	"github.com/jeffwubj/kubev/pkg/kubev/driver"
 	"github.com/jeffwubj/kubev/pkg/kubev/model"
 	"github.com/jeffwubj/kubev/pkg/kubev/utils"
 	"github.com/spf13/cobra"
@@ -27,19 +31,57 @@ import (
 )
 
 var descriptions = map[string]string{
+	"provider":          "Infrastructure provider to deploy Kubernetes nodes to, default [vsphere]",
 	"serverurl":         "vCenter/ESX URL Ex: 10.192.10.30 or myvcenter.io",
 	"port":              "vCenter/ESX port",
 	"username":          "vCenter/ESX username",
 	"password":          "vCenter/ESX password",
+	"insecure":          "Skip verifying the vCenter/ESX certificate, for labs only",
+	"cabundle":          "PEM file with the CAs that sign the vCenter/ESX certificate, instead of pinning its thumbprint",
+	"thumbprint":        "Trust the certificate with SHA-1 thumbprint %s\n  SHA-256 thumbprint %s\n?",
 	"datacenter":        "Datacenter",
 	"datastore":         "Datastore",
+	"storagepolicy":     "Storage policy to place nodes with, empty to use the datastore",
+	"datastorecluster":  "Datastore cluster to let Storage DRS place nodes in, empty to use the datastore",
 	"resourcepool":      "Resource pool to hold Kubernetese nodes, input none to not use resource pool",
 	"folder":            "VM Folder name",
+	"poolallocation":    "Set CPU/memory reservations and limits in case kubev creates the resource pool?",
+	"cpureservation":    "CPU reservation of the resource pool (MHz)",
+	"cpulimit":          "CPU limit of the resource pool (MHz), 0 for unlimited",
+	"memoryreservation": "Memory reservation of the resource pool (MB)",
+	"memorylimit":       "Memory limit of the resource pool (MB), 0 for unlimited",
 	"cpu":               "Number of vCPUs for each VM, at least 2",
 	"memory":            "Memory for each VM (MB)",
 	"network":           "Network for each VM, default [VM Network]",
 	"kubernetesversion": "Kubernetes version, e.g. [v1.13.0]",
 	"workernodes":       "Worker nodes number",
+	"linkedclone":       "Use linked clones from a template snapshot to save time and space?",
+	"contentlibrary":    "Content Library to take the node image from, empty to upload the local OVA",
+	"libraryitem":       "Content Library item of the node image, empty for the image of this kubev version",
+	"streamova":         "Stream the node image from the internet to vSphere instead of downloading it first?",
+	"commandchannel":    "Run commands in the nodes over SSH, or through VMware Tools if the node network is not reachable from here",
+	"hardwareprofile":   "Configure nodes for the vSphere CSI driver (disk UUIDs, hardware vmx-15 or later, VMXNET3 NICs, PVSCSI controller)?",
+	"dhcp":              "Is there a DHCP server in the VM network?",
+	"cidr":              "CIDR of the VM network, Ex: 10.192.10.0/24",
+	"gateway":           "Gateway of the VM network",
+	"dns":               "DNS servers, separated by comma",
+	"rangestart":        "First address kubev can assign to nodes",
+	"rangeend":          "Last address kubev can assign to nodes",
+	"nodepools":         "Size the master separately and split workers into node pools?",
+	"poolname":          "Node pool name",
+	"disksize":          "Root disk size (GB), 0 to keep the template's",
+	"replicas":          "Number of nodes in this pool",
+	"morepools":         "Add another node pool?",
+	"extranetworks":     "Extra networks for more NICs, separated by comma",
+	"nodesubnet":        "Subnet of the node IP if it cannot be told by the network, Ex: 10.192.10.0/24",
+	"failuredomains":    "Spread nodes across failure domains, e.g. compute clusters with their own datastore and network?",
+	"domainplacement":   "How to spread the nodes of each pool across failure domains",
+	"domainname":        "Failure domain name, also the topology.kubernetes.io/zone label of its nodes",
+	"computeresource":   "Compute cluster or standalone host of the failure domain",
+	"domainpool":        "Resource pool in the failure domain, empty for the root resource pool of the compute cluster or host",
+	"weight":            "Weight of the failure domain, its share of the nodes",
+	"moredomains":       "Add another failure domain?",
+	"datadisks":         "Data disks as size in GB[@datastore]:mount point, separated by comma, Ex: 50:/var/lib/docker,20:/var/lib/kubelet",
 }
 
 // configCmd represents the config command
@@ -83,6 +125,14 @@ var vcenterqs = []*survey.Question{
 		Prompt:   &survey.Input{Message: descriptions["datastore"]},
 		Validate: survey.Required,
 	},
+	{
+		Name:   "storagepolicy",
+		Prompt: &survey.Input{Message: descriptions["storagepolicy"]},
+	},
+	{
+		Name:   "datastorecluster",
+		Prompt: &survey.Input{Message: descriptions["datastorecluster"]},
+	},
 	{
 		Name:     "resourcepool",
 		Prompt:   &survey.Input{Message: descriptions["resourcepool"]},
@@ -118,6 +168,86 @@ var vcenterqs = []*survey.Question{
 		Prompt:   &survey.Input{Message: descriptions["workernodes"], Default: constants.DefaultKubernetesWorkderNodeNum},
 		Validate: survey.Required,
 	},
+	{
+		Name:   "linkedclone",
+		Prompt: &survey.Confirm{Message: descriptions["linkedclone"], Default: false},
+	},
+	{
+		Name:   "contentlibrary",
+		Prompt: &survey.Input{Message: descriptions["contentlibrary"]},
+	},
+	{
+		Name:   "streamova",
+		Prompt: &survey.Confirm{Message: descriptions["streamova"], Default: false},
+	},
+	{
+		Name:   "commandchannel",
+		Prompt: &survey.Select{Message: descriptions["commandchannel"], Options: []string{constants.SSHChannel, constants.ToolsChannel}, Default: constants.DefaultCommandChannel},
+	},
+}
+
+var ippoolqs = []*survey.Question{
+	{
+		Name:     "cidr",
+		Prompt:   &survey.Input{Message: descriptions["cidr"]},
+		Validate: survey.Required,
+	},
+	{
+		Name:     "gateway",
+		Prompt:   &survey.Input{Message: descriptions["gateway"]},
+		Validate: survey.Required,
+	},
+	{
+		Name:     "dns",
+		Prompt:   &survey.Input{Message: descriptions["dns"]},
+		Validate: survey.Required,
+	},
+	{
+		Name:     "rangestart",
+		Prompt:   &survey.Input{Message: descriptions["rangestart"]},
+		Validate: survey.Required,
+	},
+	{
+		Name:     "rangeend",
+		Prompt:   &survey.Input{Message: descriptions["rangeend"]},
+		Validate: survey.Required,
+	},
+}
+
+var poolallocationqs = []*survey.Question{
+	{
+		Name:   "cpureservation",
+		Prompt: &survey.Input{Message: descriptions["cpureservation"], Default: "0"},
+	},
+	{
+		Name:   "cpulimit",
+		Prompt: &survey.Input{Message: descriptions["cpulimit"], Default: "0"},
+	},
+	{
+		Name:   "memoryreservation",
+		Prompt: &survey.Input{Message: descriptions["memoryreservation"], Default: "0"},
+	},
+	{
+		Name:   "memorylimit",
+		Prompt: &survey.Input{Message: descriptions["memorylimit"], Default: "0"},
+	},
+}
+
+var controlplaneqs = []*survey.Question{
+	{
+		Name:     "cpu",
+		Prompt:   &survey.Input{Message: "Master: " + descriptions["cpu"], Default: constants.DefaultRemoteCPU},
+		Validate: survey.Required,
+	},
+	{
+		Name:     "memory",
+		Prompt:   &survey.Input{Message: "Master: " + descriptions["memory"], Default: constants.DefaultRemoteMemory},
+		Validate: survey.Required,
+	},
+	{
+		Name:   "disksize",
+		Prompt: &survey.Input{Message: "Master: " + descriptions["disksize"], Default: "0"},
+	},
 }
 
 var esxqs = []*survey.Question{
@@ -151,17 +281,30 @@ var esxqs = []*survey.Question{
 		Prompt:   &survey.Input{Message: descriptions["workernodes"], Default: constants.DefaultKubernetesWorkderNodeNum},
 		Validate: survey.Required,
 	},
+	{
+		Name:   "streamova",
+		Prompt: &survey.Confirm{Message: descriptions["streamova"], Default: false},
+	},
+	{
+		Name:   "commandchannel",
+		Prompt: &survey.Select{Message: descriptions["commandchannel"], Options: []string{constants.SSHChannel, constants.ToolsChannel}, Default: constants.DefaultCommandChannel},
+	},
 }
 
 func init() {
 	rootCmd.AddCommand(configCmd)
 
+	configCmd.Flags().String("provider", constants.DefaultProvider, descriptions["provider"])
 	configCmd.Flags().String("serverurl", "", descriptions["serverurl"])
 	configCmd.Flags().Int("port", 443, descriptions["port"])
 	configCmd.Flags().String("username", "", descriptions["username"])
 	configCmd.Flags().String("password", "", descriptions["password"])
+	configCmd.Flags().Bool("insecure", false, descriptions["insecure"])
+	configCmd.Flags().String("cabundle", "", descriptions["cabundle"])
 	configCmd.Flags().String("datacenter", "", descriptions["datacenter"])
 	configCmd.Flags().String("datastore", "", descriptions["datastore"])
+	configCmd.Flags().String("storagepolicy", "", descriptions["storagepolicy"])
+	configCmd.Flags().String("datastorecluster", "", descriptions["datastorecluster"])
 	configCmd.Flags().String("resourcepool", "", descriptions["resourcepool"])
 	configCmd.Flags().String("folder", "", descriptions["folder"])
 	configCmd.Flags().Int("cpu", 2, descriptions["cpu"])
@@ -169,6 +312,11 @@ func init() {
 	configCmd.Flags().String("network", "", descriptions["network"])
 	configCmd.Flags().String("kubernetesversion", "", descriptions["kubernetesversion"])
 	configCmd.Flags().Int("workernodes", 5, descriptions["workernodes"])
+	configCmd.Flags().Bool("linkedclone", false, descriptions["linkedclone"])
+	configCmd.Flags().String("contentlibrary", "", descriptions["contentlibrary"])
+	configCmd.Flags().String("libraryitem", "", descriptions["libraryitem"])
+	configCmd.Flags().Bool("streamova", false, descriptions["streamova"])
+	configCmd.Flags().String("commandchannel", constants.DefaultCommandChannel, descriptions["commandchannel"])
 	viper.BindPFlags(configCmd.Flags())
 }
 
@@ -182,14 +330,29 @@ func runConfig(cmd *cobra.Command, args []string) {
 
 func interactiveSetConfig() (*model.Answers, error) {
 	// perform the questions
-	answers := &model.Answers{}
+	answers := &model.Answers{
+		Provider: viper.GetString("provider"),
+		Insecure: viper.GetBool("insecure"),
+		CABundle: viper.GetString("cabundle"),
+	}
 	err := survey.Ask(basicqs, answers)
 	if err != nil {
 		fmt.Println(err.Error())
 		return nil, err
 	}
 
-	if err := deployer.ValidatevSphereAccount(answers); err != nil {
+	if err := askTrust(answers); err != nil {
+		fmt.Println(err.Error())
+		return nil, err
+	}
+
+	provider, err := driver.NewProvider(answers)
+	if err != nil {
+		fmt.Println(err.Error())
+		return nil, err
+	}
+	defer provider.Close()
+	if err := deployer.ValidatevSphereAccount(provider); err != nil {
 		fmt.Println(err.Error())
 		return nil, err
 	}
@@ -200,6 +363,19 @@ func interactiveSetConfig() (*model.Answers, error) {
 			fmt.Println(err.Error())
 			return nil, err
 		}
+		if answers.ContentLibrary != "" {
+			survey.AskOne(&survey.Input{Message: descriptions["libraryitem"]}, &answers.LibraryItem, nil)
+		}
+		answers.PoolAllocation, err = askPoolAllocation()
+		if err != nil {
+			fmt.Println(err.Error())
+			return nil, err
+		}
+		answers.IPPool, err = askIPPool()
+		if err != nil {
+			fmt.Println(err.Error())
+			return nil, err
+		}
 	} else {
 		err := survey.Ask(esxqs, answers)
 		if err != nil {
@@ -209,6 +385,20 @@ func interactiveSetConfig() (*model.Answers, error) {
 		answers.Datacenter = "ha-datacenter"
 	}
 
+	answers.HardwareProfile = askHardwareProfile()
+
+	if err := askNodePools(answers); err != nil {
+		fmt.Println(err.Error())
+		return nil, err
+	}
+
+	if answers.IsVCenter {
+		if err := askFailureDomains(answers); err != nil {
+			fmt.Println(err.Error())
+			return nil, err
+		}
+	}
+
 	if utils.FileExists(constants.GetK8sNodesConfigFilePath()) {
 		save := false
 		survey.AskOne(&survey.Confirm{
@@ -227,20 +417,376 @@ func interactiveSetConfig() (*model.Answers, error) {
 	return answers, nil
 }
 
+// askTrust pins the thumbprint of the server certificate once the user
+// confirmed it, unless the certificate is checked against a CA bundle or not
+// at all.
+func askTrust(answers *model.Answers) error {
+	if answers.Insecure {
+		fmt.Println("WARNING: the vCenter/ESX certificate will not be verified")
+		return nil
+	}
+	if answers.CABundle != "" {
+		if !utils.FileExists(answers.CABundle) {
+			return fmt.Errorf("Cannot find CA bundle %s", answers.CABundle)
+		}
+		return nil
+	}
+
+	sha1, sha256, err := deployer.ServerThumbprints(answers)
+	if err != nil {
+		return err
+	}
+	trust := false
+	survey.AskOne(&survey.Confirm{
+		Message: fmt.Sprintf(descriptions["thumbprint"], sha1, sha256),
+		Default: false,
+	}, &trust, nil)
+	if !trust {
+		fmt.Println("Bye")
+		return fmt.Errorf("Canceled")
+	}
+	answers.Thumbprint = sha1
+	return nil
+}
+
+// askHardwareProfile returns nil if nodes keep the hardware of the
+// template.
+func askHardwareProfile() *model.HardwareProfile {
+	csi := false
+	survey.AskOne(&survey.Confirm{
+		Message: descriptions["hardwareprofile"],
+		Default: false,
+	}, &csi, nil)
+	if !csi {
+		return nil
+	}
+	return model.NewCSIHardwareProfile()
+}
+
+// askPoolAllocation returns nil if a resource pool created by kubev needs
+// no reservations or limits.
+func askPoolAllocation() (*model.ResourceAllocation, error) {
+	custom := false
+	survey.AskOne(&survey.Confirm{
+		Message: descriptions["poolallocation"],
+		Default: false,
+	}, &custom, nil)
+	if !custom {
+		return nil, nil
+	}
+
+	allocation := &model.ResourceAllocation{}
+	if err := survey.Ask(poolallocationqs, allocation); err != nil {
+		return nil, err
+	}
+	return allocation, nil
+}
+
+// askIPPool returns nil if nodes can get their addresses from DHCP.
+func askIPPool() (*model.IPPool, error) {
+	dhcp := true
+	survey.AskOne(&survey.Confirm{
+		Message: descriptions["dhcp"],
+		Default: true,
+	}, &dhcp, nil)
+	if dhcp {
+		return nil, nil
+	}
+
+	poolanswers := struct {
+		CIDR       string
+		Gateway    string
+		DNS        string
+		RangeStart string
+		RangeEnd   string
+	}{}
+	if err := survey.Ask(ippoolqs, &poolanswers); err != nil {
+		return nil, err
+	}
+
+	pool := &model.IPPool{
+		CIDR:       poolanswers.CIDR,
+		Gateway:    poolanswers.Gateway,
+		DNS:        splitList(poolanswers.DNS),
+		RangeStart: poolanswers.RangeStart,
+		RangeEnd:   poolanswers.RangeEnd,
+	}
+	if err := utils.ValidateIPPool(pool); err != nil {
+		return nil, err
+	}
+	return pool, nil
+}
+
+// askNodePools leaves ControlPlane and NodePools nil if every VM should use
+// the settings asked before, empty answers fall back to them as well.
+func askNodePools(answers *model.Answers) error {
+	custom := false
+	survey.AskOne(&survey.Confirm{
+		Message: descriptions["nodepools"],
+		Default: false,
+	}, &custom, nil)
+	if !custom {
+		return nil
+	}
+
+	controlplane := &model.NodePool{}
+	if err := survey.Ask(controlplaneqs, controlplane); err != nil {
+		return err
+	}
+	disks, err := askDataDisks("Master: ")
+	if err != nil {
+		return err
+	}
+	controlplane.DataDisks = disks
+	answers.ControlPlane = controlplane
+
+	poolqs := []*survey.Question{
+		{
+			Name:     "name",
+			Prompt:   &survey.Input{Message: descriptions["poolname"], Default: constants.DefaultNodePoolName},
+			Validate: survey.Required,
+		},
+		{
+			Name:   "cpu",
+			Prompt: &survey.Input{Message: descriptions["cpu"], Default: fmt.Sprintf("%d", answers.Cpu)},
+		},
+		{
+			Name:   "memory",
+			Prompt: &survey.Input{Message: descriptions["memory"], Default: fmt.Sprintf("%d", answers.Memory)},
+		},
+		{
+			Name:   "disksize",
+			Prompt: &survey.Input{Message: descriptions["disksize"], Default: "0"},
+		},
+		{
+			Name:   "datastore",
+			Prompt: &survey.Input{Message: descriptions["datastore"], Default: answers.Datastore},
+		},
+		{
+			Name:   "network",
+			Prompt: &survey.Input{Message: descriptions["network"], Default: answers.Network},
+		},
+	}
+	if answers.IsVCenter {
+		poolqs = append(poolqs, &survey.Question{
+			Name:   "resourcepool",
+			Prompt: &survey.Input{Message: descriptions["resourcepool"], Default: answers.Resourcepool},
+		})
+	}
+	poolqs = append(poolqs, &survey.Question{
+		Name:     "replicas",
+		Prompt:   &survey.Input{Message: descriptions["replicas"], Default: constants.DefaultKubernetesWorkderNodeNum},
+		Validate: survey.Required,
+	})
+
+	answers.NodePools = nil
+	answers.WorkerNodes = 0
+	for {
+		pool := &model.NodePool{}
+		if err := survey.Ask(poolqs, pool); err != nil {
+			return err
+		}
+		for _, p := range answers.NodePools {
+			if p.Name == pool.Name {
+				return fmt.Errorf("Node pool %s is defined twice", pool.Name)
+			}
+		}
+		pool.DataDisks, err = askDataDisks("")
+		if err != nil {
+			return err
+		}
+		extranetworks := ""
+		survey.AskOne(&survey.Input{Message: descriptions["extranetworks"]}, &extranetworks, nil)
+		pool.ExtraNetworks = splitList(extranetworks)
+		survey.AskOne(&survey.Input{Message: descriptions["nodesubnet"]}, &pool.NodeSubnet, nil)
+		if pool.NodeSubnet != "" {
+			if _, _, err := net.ParseCIDR(pool.NodeSubnet); err != nil {
+				return err
+			}
+		}
+		answers.NodePools = append(answers.NodePools, pool)
+		answers.WorkerNodes += pool.Replicas
+
+		more := false
+		survey.AskOne(&survey.Confirm{
+			Message: descriptions["morepools"],
+			Default: false,
+		}, &more, nil)
+		if !more {
+			return nil
+		}
+	}
+}
+
+// askFailureDomains leaves FailureDomains nil if nodes are placed by their
+// pools only.
+func askFailureDomains(answers *model.Answers) error {
+	spread := false
+	survey.AskOne(&survey.Confirm{
+		Message: descriptions["failuredomains"],
+		Default: false,
+	}, &spread, nil)
+	if !spread {
+		return nil
+	}
+
+	answers.DomainPlacement = constants.RoundRobinPlacement
+	survey.AskOne(&survey.Select{
+		Message: descriptions["domainplacement"],
+		Options: []string{constants.RoundRobinPlacement, constants.WeightedPlacement},
+		Default: constants.RoundRobinPlacement,
+	}, &answers.DomainPlacement, nil)
+
+	domainqs := []*survey.Question{
+		{
+			Name:     "name",
+			Prompt:   &survey.Input{Message: descriptions["domainname"]},
+			Validate: survey.Required,
+		},
+		{
+			Name:     "computeresource",
+			Prompt:   &survey.Input{Message: descriptions["computeresource"]},
+			Validate: survey.Required,
+		},
+		{
+			Name:   "resourcepool",
+			Prompt: &survey.Input{Message: descriptions["domainpool"]},
+		},
+		{
+			Name:   "datastore",
+			Prompt: &survey.Input{Message: descriptions["datastore"], Default: answers.Datastore},
+		},
+		{
+			Name:   "network",
+			Prompt: &survey.Input{Message: descriptions["network"], Default: answers.Network},
+		},
+	}
+	if answers.IPPool == nil {
+		domainqs = append(domainqs, &survey.Question{
+			Name:   "nodesubnet",
+			Prompt: &survey.Input{Message: descriptions["nodesubnet"]},
+		})
+	}
+	if answers.DomainPlacement == constants.WeightedPlacement {
+		domainqs = append(domainqs, &survey.Question{
+			Name:   "weight",
+			Prompt: &survey.Input{Message: descriptions["weight"], Default: "1"},
+		})
+	}
+
+	answers.FailureDomains = nil
+	for {
+		domain := &model.FailureDomain{}
+		if err := survey.Ask(domainqs, domain); err != nil {
+			return err
+		}
+		for _, d := range answers.FailureDomains {
+			if d.Name == domain.Name {
+				return fmt.Errorf("Failure domain %s is defined twice", domain.Name)
+			}
+		}
+		if domain.NodeSubnet != "" {
+			if _, _, err := net.ParseCIDR(domain.NodeSubnet); err != nil {
+				return err
+			}
+		}
+		answers.FailureDomains = append(answers.FailureDomains, domain)
+		if err := deployer.ValidateFailureDomains(answers); err != nil {
+			return err
+		}
+
+		more := false
+		survey.AskOne(&survey.Confirm{
+			Message: descriptions["moredomains"],
+			Default: true,
+		}, &more, nil)
+		if !more {
+			return nil
+		}
+	}
+}
+
+func askDataDisks(prefix string) ([]*model.DataDisk, error) {
+	value := ""
+	survey.AskOne(&survey.Input{
+		Message: prefix + descriptions["datadisks"],
+	}, &value, nil)
+	return parseDataDisks(value)
+}
+
+// parseDataDisks reads disks written as size[@datastore]:mountpoint.
+func parseDataDisks(s string) ([]*model.DataDisk, error) {
+	disks := []*model.DataDisk{}
+	for _, item := range splitList(s) {
+		kv := strings.SplitN(item, ":", 2)
+		if len(kv) != 2 || !strings.HasPrefix(kv[1], "/") {
+			return nil, fmt.Errorf("Invalid data disk %s, use size in GB[@datastore]:mount point", item)
+		}
+		disk := &model.DataDisk{MountPoint: kv[1]}
+		size := strings.SplitN(kv[0], "@", 2)
+		if len(size) == 2 {
+			disk.Datastore = size[1]
+		}
+		n, err := strconv.Atoi(size[0])
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("Invalid size of data disk %s", item)
+		}
+		disk.Size = n
+		disks = append(disks, disk)
+	}
+	return disks, nil
+}
+
+func splitList(s string) []string {
+	list := []string{}
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
 func SaveAnswers(answers *model.Answers) {
+	viper.Set("provider", answers.Provider)
 	viper.Set("serverurl", answers.Serverurl)
 	viper.Set("port", answers.Port)
 	viper.Set("username", answers.Username)
 	viper.Set("password", answers.Password)
+	viper.Set("insecure", answers.Insecure)
+	viper.Set("thumbprint", answers.Thumbprint)
+	viper.Set("cabundle", answers.CABundle)
 	viper.Set("datacenter", answers.Datacenter)
 	viper.Set("datastore", answers.Datastore)
+	viper.Set("storagepolicy", answers.StoragePolicy)
+	viper.Set("datastorecluster", answers.DatastoreCluster)
 	viper.Set("resourcepool", answers.Resourcepool)
 	viper.Set("folder", answers.Folder)
+	viper.Set("poolallocation", answers.PoolAllocation)
+	viper.Set("hardwareprofile", answers.HardwareProfile)
 	viper.Set("cpu", answers.Cpu)
 	viper.Set("memory", answers.Memory)
 	viper.Set("network", answers.Network)
 	viper.Set("kubernetesVersion", answers.KubernetesVersion)
 	viper.Set("workernodes", answers.WorkerNodes)
 	viper.Set("isvcenter", answers.IsVCenter)
+	viper.Set("linkedclone", answers.LinkedClone)
+	viper.Set("contentlibrary", answers.ContentLibrary)
+	viper.Set("libraryitem", answers.LibraryItem)
+	viper.Set("streamova", answers.StreamOVA)
+	viper.Set("commandchannel", answers.CommandChannel)
+	pool := answers.IPPool
+	if pool == nil {
+		pool = &model.IPPool{}
+	}
+	viper.Set("ippool.cidr", pool.CIDR)
+	viper.Set("ippool.gateway", pool.Gateway)
+	viper.Set("ippool.dns", strings.Join(pool.DNS, ","))
+	viper.Set("ippool.rangestart", pool.RangeStart)
+	viper.Set("ippool.rangeend", pool.RangeEnd)
+	viper.Set("controlplane", answers.ControlPlane)
+	viper.Set("nodepools", answers.NodePools)
+	viper.Set("failuredomains", answers.FailureDomains)
+	viper.Set("domainplacement", answers.DomainPlacement)
 	viper.WriteConfigAs(viper.ConfigFileUsed())
 }
@@ -21,6 +21,7 @@ import (
 
 	"github.com/jeffwubj/kubev/pkg/kubev/cacher"
 	"github.com/jeffwubj/kubev/pkg/kubev/deployer"
+	"github.com/jeffwubj/kubev/pkg/kubev/driver"
 	"github.com/jeffwubj/kubev/pkg/kubev/model"
 	"github.com/jeffwubj/kubev/pkg/kubev/utils"
 	"github.com/spf13/cobra"
@@ -69,13 +70,23 @@ func runDeploy(cmd *cobra.Command, args []string) {
 		fmt.Println(err.Error())
 		return
 	}
+	provider, err := driver.NewProvider(answers)
+	if err != nil {
+		fmt.Println(err.Error())
+		return
+	}
+	defer provider.Close()
 
-	vmconfig, err := deployer.FindMasterNode(answers)
+	masters, err := deployer.FindMasterNodes(provider)
+	if err != nil {
+		fmt.Println(err.Error())
+		return
+	}
 
-	if vmconfig != nil {
+	if len(masters) > 0 {
 		overwrite := false
 		survey.AskOne(&survey.Confirm{
-			Message: fmt.Sprintf(`Found Kubernetes master node %s, do you want to overwrite this cluster? (You should use kubev scale to change existing cluster or kubev recover to re-manage it)`, vmconfig.VMName),
+			Message: fmt.Sprintf(`Found Kubernetes master node %s, do you want to overwrite this cluster? (You should use kubev scale to change existing cluster or kubev recover to re-manage it)`, masters[0].VMName),
 			Default: false,
 		}, &overwrite, nil)
 		if !overwrite {
@@ -84,14 +95,19 @@ func runDeploy(cmd *cobra.Command, args []string) {
 		}
 	}
 
+	passed, err := preflight(provider, answers)
 	if err != nil {
 		fmt.Println(err.Error())
 		return
 	}
+	if !passed {
+		fmt.Println("Preflight checks failed, fix them and deploy again")
+		return
+	}
 
-	cacher.CacheAll(viper.GetString("kubernetesversion"))
+	cacher.CacheAll(viper.GetString("kubernetesversion"), needOVA(answers))
 
-	vms, err = deployer.DeployNodes(answers)
+	vms, err = deployer.DeployNodes(provider, answers)
 	if err != nil {
 		fmt.Println("Deploy nodes failed...")
 		fmt.Println(err.Error())
@@ -113,20 +129,77 @@ func readConfig() (*model.Answers, error) {
 
 	viper.ReadConfig(bytes.NewBuffer(dat))
 
+	var pool *model.IPPool
+	if viper.GetString("ippool.cidr") != "" {
+		pool = &model.IPPool{
+			CIDR:       viper.GetString("ippool.cidr"),
+			Gateway:    viper.GetString("ippool.gateway"),
+			DNS:        splitList(viper.GetString("ippool.dns")),
+			RangeStart: viper.GetString("ippool.rangestart"),
+			RangeEnd:   viper.GetString("ippool.rangeend"),
+		}
+	}
+
+	var allocation *model.ResourceAllocation
+	if err := viper.UnmarshalKey("poolallocation", &allocation); err != nil {
+		return nil, err
+	}
+	var hardware *model.HardwareProfile
+	if err := viper.UnmarshalKey("hardwareprofile", &hardware); err != nil {
+		return nil, err
+	}
+	var controlplane *model.NodePool
+	if err := viper.UnmarshalKey("controlplane", &controlplane); err != nil {
+		return nil, err
+	}
+	var nodepools []*model.NodePool
+	if err := viper.UnmarshalKey("nodepools", &nodepools); err != nil {
+		return nil, err
+	}
+	var domains []*model.FailureDomain
+	if err := viper.UnmarshalKey("failuredomains", &domains); err != nil {
+		return nil, err
+	}
+
 	return &model.Answers{
+		Provider:          viper.GetString("provider"),
 		Serverurl:         viper.GetString("serverurl"),
 		Port:              viper.GetInt("port"),
 		Username:          viper.GetString("username"),
 		Password:          viper.GetString("password"),
+		Insecure:          viper.GetBool("insecure"),
+		Thumbprint:        viper.GetString("thumbprint"),
+		CABundle:          viper.GetString("cabundle"),
 		Datacenter:        viper.GetString("datacenter"),
 		Datastore:         viper.GetString("datastore"),
+		StoragePolicy:     viper.GetString("storagepolicy"),
+		DatastoreCluster:  viper.GetString("datastorecluster"),
 		Resourcepool:      viper.GetString("resourcepool"),
 		Folder:            viper.GetString("folder"),
+		PoolAllocation:    allocation,
+		HardwareProfile:   hardware,
 		Cpu:               viper.GetInt("cpu"),
 		Memory:            viper.GetInt("memory"),
 		Network:           viper.GetString("network"),
 		KubernetesVersion: viper.GetString("kubernetesversion"),
 		WorkerNodes:       viper.GetInt("workernodes"),
 		IsVCenter:         viper.GetBool("isvcenter"),
+		LinkedClone:       viper.GetBool("linkedclone"),
+		ContentLibrary:    viper.GetString("contentlibrary"),
+		LibraryItem:       viper.GetString("libraryitem"),
+		StreamOVA:         viper.GetBool("streamova"),
+		CommandChannel:    viper.GetString("commandchannel"),
+		IPPool:            pool,
+		ControlPlane:      controlplane,
+		NodePools:         nodepools,
+		FailureDomains:    domains,
+		DomainPlacement:   viper.GetString("domainplacement"),
 	}, nil
 }
+
+// needOVA tells if the OVA has to be in the local cache, it is streamed
+// from its URL otherwise. Content Libraries are always published from the
+// cache.
+func needOVA(answers *model.Answers) bool {
+	return !answers.StreamOVA || answers.ContentLibrary != ""
+}
@@ -19,6 +19,7 @@ import (
 
 	"github.com/jeffwubj/kubev/pkg/kubev/constants"
 	"github.com/jeffwubj/kubev/pkg/kubev/deployer"
+	"github.com/jeffwubj/kubev/pkg/kubev/driver"
 	"github.com/jeffwubj/kubev/pkg/kubev/utils"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -83,8 +84,14 @@ func runDestory(cmd *cobra.Command, args []string) {
 		fmt.Println(err.Error())
 		return
 	}
+	provider, err := driver.NewProvider(answers)
+	if err != nil {
+		fmt.Println(err.Error())
+		return
+	}
+	defer provider.Close()
 
-	err = deployer.Destory(answers, vms)
+	err = deployer.Destory(provider, vms)
 	if err != nil {
 		fmt.Println("Destory nodes failed...")
 		fmt.Println(err.Error())
@@ -0,0 +1,98 @@
+// Copyright © 2019 Jeff Wu <jeff.wu.junfei@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+
+	"github.com/jeffwubj/kubev/pkg/kubev/deployer"
+	"github.com/jeffwubj/kubev/pkg/kubev/driver"
+	"github.com/jeffwubj/kubev/pkg/kubev/model"
+	"github.com/jeffwubj/kubev/pkg/kubev/utils"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// eventsCmd represents the events command
+var eventsCmd = &cobra.Command{
+	Use:   "events",
+	Short: "Show vCenter/ESX events of the cluster VMs",
+	Long: `Shows the events and finished tasks of every node VM and of the template, e.g. to
+see why a deployment waits for an IP`,
+	Run: runEvents,
+}
+
+func init() {
+	eventsCmd.Flags().BoolP("follow", "f", false, "Keep showing new events until interrupted")
+	eventsCmd.Flags().IntP("count", "n", 25, "Number of recent events of each VM")
+	eventsCmd.Flags().Bool("json", false, "Print one JSON object per event")
+	rootCmd.AddCommand(eventsCmd)
+}
+
+func runEvents(cmd *cobra.Command, args []string) {
+	if !utils.FileExists(viper.ConfigFileUsed()) {
+		fmt.Println("There is no config file, run 'kubev config' first")
+		return
+	}
+	answers, err := readConfig()
+	if err != nil {
+		fmt.Println(err.Error())
+		return
+	}
+	provider, err := driver.NewProvider(answers)
+	if err != nil {
+		fmt.Println(err.Error())
+		return
+	}
+	defer provider.Close()
+	// a deployment in progress may not have saved every node yet
+	vms, err := utils.ReadK8sNodes()
+	if err != nil {
+		fmt.Println(err.Error())
+		return
+	}
+
+	follow, _ := cmd.Flags().GetBool("follow")
+	count, _ := cmd.Flags().GetInt("count")
+	asJSON, _ := cmd.Flags().GetBool("json")
+
+	var stop chan struct{}
+	if follow {
+		stop = make(chan struct{})
+		sigs := make(chan os.Signal, 1)
+		signal.Notify(sigs, os.Interrupt)
+		defer signal.Stop(sigs)
+		go func() {
+			<-sigs
+			close(stop)
+		}()
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	err = deployer.Events(provider, vms, count, stop, func(e *model.Event) error {
+		if asJSON {
+			return encoder.Encode(e)
+		}
+		fmt.Printf("%s  %-7s  %-20s  %s\n", e.Time.Local().Format("2006-01-02 15:04:05"), e.Severity, e.VM, e.Message)
+		return nil
+	})
+	if err != nil {
+		fmt.Println(err.Error())
+		return
+	}
+}
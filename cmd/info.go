@@ -16,8 +16,14 @@ package cmd
 
 import (
 	"fmt"
+	"io/ioutil"
 	"os"
+	"strings"
 
+	"github.com/jeffwubj/kubev/pkg/kubev/constants"
+	"github.com/jeffwubj/kubev/pkg/kubev/deployer"
+	"github.com/jeffwubj/kubev/pkg/kubev/driver"
+	"github.com/jeffwubj/kubev/pkg/kubev/model"
 	"github.com/jeffwubj/kubev/pkg/kubev/utils"
 	"github.com/olekukonko/tablewriter"
 	"github.com/spf13/cobra"
@@ -33,6 +39,8 @@ var infoCmd = &cobra.Command{
 }
 
 func init() {
+	infoCmd.Flags().Bool("hardware", false, "Check whether the node VMs match the hardware profile")
+	infoCmd.Flags().String("export-key", "", "Write the SSH key of the nodes to this file for 'kubev use --key', it gives root on every node")
 	rootCmd.AddCommand(infoCmd)
 }
 
@@ -63,14 +71,77 @@ func runInfo(cmd *cobra.Command, args []string) {
 	token := utils.EncodeToken(vms.MasterNode)
 	fmt.Printf("Use 'kubev use --token %s' in other machine to use this cluster\n", token)
 
+	if keyfile, _ := cmd.Flags().GetString("export-key"); keyfile != "" {
+		key, err := ioutil.ReadFile(constants.GetVMPrivateKeyPath())
+		if err != nil {
+			fmt.Println(err.Error())
+			return
+		}
+		if err := ioutil.WriteFile(keyfile, key, 0600); err != nil {
+			fmt.Println(err.Error())
+			return
+		}
+		fmt.Printf("SSH key of the nodes written to %s, keep it as secret as the cluster\n", keyfile)
+	}
+
+	hardware, _ := cmd.Flags().GetBool("hardware")
+	var provider driver.Provider
+	if hardware {
+		provider, err = driver.NewProvider(answers)
+		if err != nil {
+			fmt.Println(err.Error())
+			return
+		}
+		defer provider.Close()
+	}
+	zones := len(answers.FailureDomains) > 0
+
 	data := [][]string{}
-	data = append(data, []string{vms.MasterNode.VMName, "master", vms.MasterNode.IP})
-	for _, vm := range vms.WorkerNodes {
-		data = append(data, []string{vm.VMName, "worker", vm.IP})
+	deployer.ResolveNodePools(answers, vms)
+	for _, vm := range vms.AllNodes() {
+		role := "worker"
+		if vm == vms.MasterNode {
+			role = "master"
+		}
+		row := []string{vm.VMName, role, vm.Pool, vm.IP, cloneMode(vm)}
+		if zones {
+			row = append(row, vm.FailureDomain)
+		}
+		if hardware {
+			row = append(row, hardwareCompliance(provider, vm))
+		}
+		data = append(data, row)
+	}
+	header := []string{"NAME", "ROLES", "POOL", "IP", "CLONE"}
+	if zones {
+		header = append(header, "ZONE")
+	}
+	if hardware {
+		header = append(header, "HARDWARE")
 	}
 	table := tablewriter.NewWriter(os.Stdout)
-	table.SetHeader([]string{"NAME", "ROLES", "IP"})
+	table.SetHeader(header)
 	table.SetBorder(true)
 	table.AppendBulk(data)
 	table.Render()
 }
+
+func cloneMode(vm *model.K8sNode) string {
+	if vm.CloneMode == "" {
+		return "unknown"
+	}
+	return vm.CloneMode
+}
+
+// hardwareCompliance returns ok if vm matches the hardware profile, or what
+// differs.
+func hardwareCompliance(provider driver.Provider, vm *model.K8sNode) string {
+	mismatches, err := deployer.HardwareMismatches(provider, vm)
+	if err != nil {
+		return err.Error()
+	}
+	if len(mismatches) == 0 {
+		return "ok"
+	}
+	return strings.Join(mismatches, "\n")
+}
@@ -0,0 +1,90 @@
+// Copyright © 2019 Jeff Wu <jeff.wu.junfei@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/jeffwubj/kubev/pkg/kubev/constants"
+	"github.com/jeffwubj/kubev/pkg/kubev/deployer"
+	"github.com/jeffwubj/kubev/pkg/kubev/driver"
+	"github.com/jeffwubj/kubev/pkg/kubev/model"
+	"github.com/jeffwubj/kubev/pkg/kubev/utils"
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// preflightCmd represents the preflight command
+var preflightCmd = &cobra.Command{
+	Use:   "preflight",
+	Short: "Check privileges, capacity and inventory before deploying",
+	Long:  `Checks the vCenter/ESX in the config file can hold the cluster, deploy runs the same checks`,
+	Run:   runPreflight,
+}
+
+func init() {
+	rootCmd.AddCommand(preflightCmd)
+}
+
+func runPreflight(cmd *cobra.Command, args []string) {
+	if !utils.FileExists(viper.ConfigFileUsed()) {
+		fmt.Println("There is no config file, run config before preflight")
+		return
+	}
+
+	answers, err := readConfig()
+	if err != nil {
+		fmt.Println(err.Error())
+		return
+	}
+	provider, err := driver.NewProvider(answers)
+	if err != nil {
+		fmt.Println(err.Error())
+		return
+	}
+	defer provider.Close()
+
+	if _, err := preflight(provider, answers); err != nil {
+		fmt.Println(err.Error())
+		return
+	}
+}
+
+// preflight prints the report of the preflight checks and returns false if
+// any of them failed.
+func preflight(provider driver.Provider, answers *model.Answers) (bool, error) {
+	fmt.Println("Run preflight checks ...")
+	checks, err := deployer.Preflight(provider, answers)
+	if err != nil {
+		return false, err
+	}
+
+	counts := map[string]int{}
+	data := [][]string{}
+	for _, check := range checks {
+		counts[check.Status]++
+		data = append(data, []string{check.Name, check.Status, check.Message})
+	}
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"CHECK", "RESULT", "DETAIL"})
+	table.SetBorder(true)
+	table.AppendBulk(data)
+	table.Render()
+
+	fmt.Printf("%d passed, %d warnings, %d failed\n", counts[constants.PreflightPass], counts[constants.PreflightWarn], counts[constants.PreflightFail])
+	return counts[constants.PreflightFail] == 0, nil
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 
 	"github.com/jeffwubj/kubev/pkg/kubev/deployer"
+	"github.com/jeffwubj/kubev/pkg/kubev/driver"
 	"github.com/jeffwubj/kubev/pkg/kubev/utils"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -26,12 +27,24 @@ func runPrint(cmd *cobra.Command, args []string) {
 		return
 	}
 
+	answers, err := readConfig()
+	if err != nil {
+		fmt.Println(err.Error())
+		return
+	}
+	provider, err := driver.NewProvider(answers)
+	if err != nil {
+		fmt.Println(err.Error())
+		return
+	}
+	defer provider.Close()
+
 	vms, err := utils.ReadK8sNodes()
 	if err != nil {
 		fmt.Println(err.Error())
 		return
 	}
-	joincmd, err := deployer.GetKubeAdmJoinCommand(vms.MasterNode)
+	joincmd, err := deployer.GetKubeAdmJoinCommand(provider, answers, vms.MasterNode)
 	if err != nil {
 		fmt.Printf("Failed to join master node: %s\n", err.Error())
 		return
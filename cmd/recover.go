@@ -16,10 +16,12 @@ package cmd
 
 import (
 	"fmt"
+	"os"
 
 	"github.com/jeffwubj/kubev/pkg/kubev/cacher"
 	"github.com/jeffwubj/kubev/pkg/kubev/constants"
 	"github.com/jeffwubj/kubev/pkg/kubev/deployer"
+	"github.com/jeffwubj/kubev/pkg/kubev/driver"
 	"github.com/jeffwubj/kubev/pkg/kubev/model"
 	"github.com/jeffwubj/kubev/pkg/kubev/utils"
 	"github.com/spf13/cobra"
@@ -36,16 +38,37 @@ var recoverCmd = &cobra.Command{
 
 func init() {
 	rootCmd.AddCommand(recoverCmd)
+
+	recoverCmd.Flags().Bool("insecure", false, descriptions["insecure"])
+	recoverCmd.Flags().String("cabundle", "", descriptions["cabundle"])
+	recoverCmd.Flags().String("cluster", "", "ID of the cluster to recover, kubev asks if there are several")
 }
 
 func runRecover(cmd *cobra.Command, args []string) {
+	if !utils.FileExists(constants.GetVMPrivateKeyPath()) {
+		fmt.Println("Nodes only accept the kubev SSH key, export it with 'kubev info --export-key <file>' where the cluster was deployed and run 'kubev use --token <token> --key <file>' first")
+		return
+	}
+
 	answers := &model.Answers{}
+	answers.Insecure, _ = cmd.Flags().GetBool("insecure")
+	answers.CABundle, _ = cmd.Flags().GetString("cabundle")
 	err := survey.Ask(basicqs, answers)
 	if err != nil {
 		fmt.Println(err.Error())
 		return
 	}
-	if err := deployer.ValidatevSphereAccount(answers); err != nil {
+	if err := askTrust(answers); err != nil {
+		fmt.Println(err.Error())
+		return
+	}
+	provider, err := driver.NewProvider(answers)
+	if err != nil {
+		fmt.Println(err.Error())
+		return
+	}
+	defer provider.Close()
+	if err := deployer.ValidatevSphereAccount(provider); err != nil {
 		fmt.Println(err.Error())
 		return
 	}
@@ -59,7 +82,15 @@ func runRecover(cmd *cobra.Command, args []string) {
 	}
 
 	fmt.Println("Searching...")
-	vmconfig, err := deployer.FindMasterNode(answers)
+	clusterID, _ := cmd.Flags().GetString("cluster")
+	if clusterID == "" {
+		clusterID, err = selectCluster(provider)
+		if err != nil {
+			fmt.Println(err.Error())
+			return
+		}
+	}
+	vmconfig, err := deployer.FindMasterNode(provider, clusterID)
 	if err != nil {
 		fmt.Println(err.Error())
 		return
@@ -72,16 +103,32 @@ func runRecover(cmd *cobra.Command, args []string) {
 
 	fmt.Printf("Found master node at %s\n", vmconfig.IP)
 
-	answers, _, err = RecoverConfigFilesFromMaster(answers, vmconfig)
+	answers, vms, err := RecoverConfigFilesFromMaster(answers, vmconfig)
+	if err != nil {
+		fmt.Println(err.Error())
+		return
+	}
+
+	// the downloaded config tells where the nodes are
+	recovered, err := driver.NewProvider(answers)
 	if err != nil {
 		fmt.Println(err.Error())
 		return
 	}
+	defer recovered.Close()
+	if err := deployer.RecoverClusterNodes(recovered, vms); err != nil {
+		fmt.Println(err.Error())
+		return
+	}
+	if err := utils.SaveK8sNodes(vms); err != nil {
+		fmt.Println(err.Error())
+		return
+	}
 
 	fmt.Println("Configuration files recovered")
 
 	fmt.Printf("Cache %s kits...\n", answers.KubernetesVersion)
-	cacher.CacheAll(answers.KubernetesVersion)
+	cacher.CacheAll(answers.KubernetesVersion, needOVA(answers))
 
 	fmt.Println("All recovered")
 
@@ -89,6 +136,37 @@ func runRecover(cmd *cobra.Command, args []string) {
 
 }
 
+// selectCluster asks which cluster to recover if the infrastructure has
+// more than one.
+func selectCluster(provider driver.Provider) (string, error) {
+	masters, err := deployer.FindMasterNodes(provider)
+	if err != nil {
+		return "", err
+	}
+	if len(masters) == 0 {
+		return "", fmt.Errorf("Cannot find a master node")
+	}
+	if len(masters) == 1 {
+		return masters[0].ClusterID, nil
+	}
+
+	options := []string{}
+	clusters := map[string]string{}
+	for _, master := range masters {
+		option := fmt.Sprintf("%s (master %s)", master.ClusterID, master.VMName)
+		options = append(options, option)
+		clusters[option] = master.ClusterID
+	}
+	selected := ""
+	if err := survey.AskOne(&survey.Select{
+		Message: "Found multiple clusters, which one to recover?",
+		Options: options,
+	}, &selected, nil); err != nil {
+		return "", err
+	}
+	return clusters[selected], nil
+}
+
 func RecoverConfigFilesFromMaster(answers *model.Answers, vmconfig *model.K8sNode) (*model.Answers, *model.K8sNodes, error) {
 	if err := deployer.CopyRemoteFileToLocal(vmconfig, constants.GetRemoteK8sNodesConfigFilePath(), constants.GetK8sNodesConfigFilePath()); err != nil {
 		fmt.Println("Failed to download meta data")
@@ -110,6 +188,9 @@ func RecoverConfigFilesFromMaster(answers *model.Answers, vmconfig *model.K8sNod
 	}
 	downloadanswers.Username = answers.Username
 	downloadanswers.Password = answers.Password
+	downloadanswers.Insecure = answers.Insecure
+	downloadanswers.Thumbprint = answers.Thumbprint
+	downloadanswers.CABundle = answers.CABundle
 	SaveAnswers(downloadanswers)
 
 	if err := deployer.CopyRemoteFileToLocal(vmconfig, constants.GetRemoteVMPrivateKeyPath(), constants.GetVMPrivateKeyPath()); err != nil {
@@ -122,6 +203,14 @@ func RecoverConfigFilesFromMaster(answers *model.Answers, vmconfig *model.K8sNod
 		return nil, nil, err
 	}
 
+	if downloadanswers.CommandChannel == constants.ToolsChannel {
+		if err := deployer.CopyRemoteFileToLocal(vmconfig, constants.GetRemoteVMPasswordPath(), constants.GetVMPasswordPath()); err != nil {
+			fmt.Println("Failed to download meta data")
+			return nil, nil, err
+		}
+		os.Chmod(constants.GetVMPasswordPath(), 0600)
+	}
+
 	if err := deployer.DownloadKubeCtlConfig(vmconfig); err != nil {
 		fmt.Println("Failed to download meta data")
 		return nil, nil, err
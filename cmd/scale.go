@@ -18,6 +18,7 @@ import (
 	"fmt"
 
 	"github.com/jeffwubj/kubev/pkg/kubev/deployer"
+	"github.com/jeffwubj/kubev/pkg/kubev/driver"
 	"github.com/jeffwubj/kubev/pkg/kubev/model"
 	"github.com/jeffwubj/kubev/pkg/kubev/utils"
 	"github.com/spf13/cobra"
@@ -29,12 +30,13 @@ import (
 var scaleCmd = &cobra.Command{
 	Use:   "scale",
 	Short: "Add more workder nodes or remove existing workder nodes",
-	Long:  ``,
+	Long:  `Change the number of worker nodes in a node pool, use --pool to choose the pool if the cluster has more than one`,
 	Run:   runScale,
 }
 
 func init() {
 	rootCmd.AddCommand(scaleCmd)
+	scaleCmd.Flags().String("pool", "", "Node pool to scale")
 }
 
 func runScale(cmd *cobra.Command, args []string) {
@@ -48,18 +50,45 @@ func runScale(cmd *cobra.Command, args []string) {
 		fmt.Println(err.Error())
 		return
 	}
+	provider, err := driver.NewProvider(answers)
+	if err != nil {
+		fmt.Println(err.Error())
+		return
+	}
+	defer provider.Close()
 
 	vms, err := utils.ReadK8sNodes()
 	if err != nil {
 		fmt.Println(err.Error())
 		return
 	}
+	deployer.ResolveNodePools(answers, vms)
+
+	poolname, _ := cmd.Flags().GetString("pool")
+	if poolname == "" {
+		names := []string{}
+		for _, pool := range vms.NodePools {
+			names = append(names, pool.Name)
+		}
+		poolname = names[0]
+		if len(names) > 1 {
+			survey.AskOne(&survey.Select{
+				Message: "Which node pool do you want to scale?",
+				Options: names,
+			}, &poolname, nil)
+		}
+	}
+	pool := vms.NodePool(poolname)
+	if pool == nil {
+		fmt.Printf("Cannot find node pool %s\n", poolname)
+		return
+	}
 
-	workernodes := vms.WorkerNodes
+	current := len(vms.PoolNodes(pool.Name))
 askagain:
-	number := len(workernodes)
+	number := current
 	survey.AskOne(&survey.Input{
-		Message: fmt.Sprintf("There are %d worker nodes in current cluster, how many workder nodes do you want?", number),
+		Message: fmt.Sprintf("There are %d worker nodes in pool %s, how many workder nodes do you want?", number, pool.Name),
 	}, &number, nil)
 
 	if number <= 0 || number > 1000 {
@@ -67,60 +96,73 @@ askagain:
 		goto askagain
 	}
 
-	if answers.WorkerNodes == number {
-		fmt.Printf("Cluster already has %d worker nodes, no extra action needed", number)
-	} else if answers.WorkerNodes > number { // DELETE
-		fmt.Printf("Changing cluster with %d nodes...\n", number)
-		todelete := answers.WorkerNodes - number
+	if current == number {
+		fmt.Printf("Pool %s already has %d worker nodes, no extra action needed", pool.Name, number)
+	} else if current > number { // DELETE
+		fmt.Printf("Changing pool %s with %d nodes...\n", pool.Name, number)
+		todelete := current - number
 		for i := 0; i < todelete; i++ {
-			x := workernodes[len(workernodes)-1]
-			workernodes = workernodes[:len(workernodes)-1]
-			if err := deployer.DestorySingle(answers, x); err != nil {
+			poolnodes := vms.PoolNodes(pool.Name)
+			x := poolnodes[len(poolnodes)-1]
+			if err := deployer.DestorySingle(provider, x); err != nil {
 				fmt.Printf("Failed to delete %s: %s\n", x.VMName, err.Error())
 				break
 			}
-			if err := deployer.DeleteWorkerNodeFromKubenretes(x, vms); err != nil {
+			if err := deployer.DeleteWorkerNodeFromKubenretes(provider, answers, x, vms); err != nil {
 				fmt.Printf("Failed to remove %s: %s\n", x.VMName, err.Error())
 				break
 			}
-			answers.WorkerNodes = answers.WorkerNodes - 1
+			// dropping the node releases its static IP back to the pool, only
+			// once nothing holds it anymore
+			vms.WorkerNodes = removeNode(vms.WorkerNodes, x)
 		}
 	} else { // ADD
-		joincmd, err := deployer.GetKubeAdmJoinCommand(vms.MasterNode)
+		joincmd, err := deployer.GetKubeAdmJoinCommand(provider, answers, vms.MasterNode)
 		if err != nil {
 			fmt.Printf("Failed to join master node: %s\n", err.Error())
 			return
 		}
 		vms.JoinString = joincmd
-		fmt.Printf("Changing cluster with %d nodes...\n", number)
-		toadd := number - answers.WorkerNodes
-		worker := "kubev-esx-worker"
-		if answers.IsVCenter {
-			worker = "kubev-vc-worker"
-		}
+		fmt.Printf("Changing pool %s with %d nodes...\n", pool.Name, number)
+		toadd := number - current
 		for i := 0; i < toadd; i++ {
-			newnode := &model.K8sNode{
-				MasterNode: false,
-				VMName:     fmt.Sprintf("%s-%d", worker, answers.WorkerNodes+1),
-				Ready:      false,
-			}
-			if err := deployer.DeployWorkderNode(newnode, answers, vms); err != nil {
+			newnode := deployer.NewPoolNode(answers, vms, pool)
+			if err := deployer.DeployWorkderNode(provider, newnode, answers, vms); err != nil {
 				fmt.Printf("Failed to add new worker node %s: %s\n", newnode.VMName, err.Error())
 				break
 			} else {
-				workernodes = append(workernodes, newnode)
-				answers.WorkerNodes = answers.WorkerNodes + 1
+				vms.WorkerNodes = append(vms.WorkerNodes, newnode)
 			}
 		}
 
 	}
 
+	pool.Replicas = len(vms.PoolNodes(pool.Name))
+	for _, p := range answers.NodePools {
+		if p.Name == pool.Name {
+			p.Replicas = pool.Replicas
+		}
+	}
+	answers.WorkerNodes = len(vms.WorkerNodes)
+
 	// TODO Below should be called for every node added/deleted, otherwise, ctl+c will break all configurations
-	vms.WorkerNodes = workernodes
+	if err := deployer.UpdatePlacement(provider, vms); err != nil {
+		fmt.Printf("Failed to update placement rules: %s\n", err.Error())
+	}
 	utils.SaveK8sNodes(vms)
 	SaveAnswers(answers)
-	err = deployer.UploadConfigToMasterNode(answers, vms)
+	err = deployer.UploadConfigToMasterNode(provider, answers, vms)
 	if err != nil {
 		fmt.Println("Failed to upload kubev config to the cluster")
 	}
 }
+
+func removeNode(nodes []*model.K8sNode, node *model.K8sNode) []*model.K8sNode {
+	left := []*model.K8sNode{}
+	for _, n := range nodes {
+		if n != node {
+			left = append(left, n)
+		}
+	}
+	return left
+}
@@ -0,0 +1,227 @@
+// Copyright © 2019 Jeff Wu <jeff.wu.junfei@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/jeffwubj/kubev/pkg/kubev/deployer"
+	"github.com/jeffwubj/kubev/pkg/kubev/driver"
+	"github.com/jeffwubj/kubev/pkg/kubev/model"
+	"github.com/jeffwubj/kubev/pkg/kubev/utils"
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	survey "gopkg.in/AlecAivazis/survey.v1"
+)
+
+// snapshotCmd represents the snapshot command
+var snapshotCmd = &cobra.Command{
+	Use:   "snapshot",
+	Short: "Snapshot all nodes of the cluster together",
+	Long: `Every node of the cluster gets a snapshot with the same name, so the whole cluster
+can be reverted to the same point`,
+}
+
+var snapshotCreateCmd = &cobra.Command{
+	Use:   "create <name>",
+	Short: "Snapshot all nodes",
+	Long:  ``,
+	Args:  cobra.ExactArgs(1),
+	Run:   runSnapshotCreate,
+}
+
+var snapshotListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List snapshots of the cluster",
+	Long:  ``,
+	Run:   runSnapshotList,
+}
+
+var snapshotRevertCmd = &cobra.Command{
+	Use:   "revert <name>",
+	Short: "Revert all nodes to a snapshot, master first",
+	Long:  ``,
+	Args:  cobra.ExactArgs(1),
+	Run:   runSnapshotRevert,
+}
+
+var snapshotDeleteCmd = &cobra.Command{
+	Use:   "delete <name>",
+	Short: "Delete a snapshot of all nodes",
+	Long:  ``,
+	Args:  cobra.ExactArgs(1),
+	Run:   runSnapshotDelete,
+}
+
+func init() {
+	snapshotCreateCmd.Flags().Bool("memory", false, "Include the memory of the running VMs")
+	snapshotCreateCmd.Flags().Bool("quiesce", false, "Quiesce the guest file systems, requires VMware Tools")
+	snapshotCreateCmd.Flags().String("description", "Created by kubev", "Description of the snapshot")
+	snapshotCmd.AddCommand(snapshotCreateCmd)
+	snapshotCmd.AddCommand(snapshotListCmd)
+	snapshotCmd.AddCommand(snapshotRevertCmd)
+	snapshotCmd.AddCommand(snapshotDeleteCmd)
+	rootCmd.AddCommand(snapshotCmd)
+}
+
+// readCluster reads the config and the nodes of the local cluster.
+func readCluster() (*model.Answers, *model.K8sNodes, error) {
+	if !utils.FileExists(viper.ConfigFileUsed()) {
+		return nil, nil, fmt.Errorf("There is no config file, deploy a cluster or run 'kubev recover' to find an existing cluster")
+	}
+
+	answers, err := readConfig()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	vms, err := utils.ReadK8sNodes()
+	if err != nil {
+		return nil, nil, err
+	}
+	if vms == nil || vms.MasterNode == nil {
+		return nil, nil, fmt.Errorf("There is no cluster, deploy a cluster or run 'kubev recover' to find an existing cluster")
+	}
+	return answers, vms, nil
+}
+
+func runSnapshotCreate(cmd *cobra.Command, args []string) {
+	answers, vms, err := readCluster()
+	if err != nil {
+		fmt.Println(err.Error())
+		return
+	}
+	provider, err := driver.NewProvider(answers)
+	if err != nil {
+		fmt.Println(err.Error())
+		return
+	}
+	defer provider.Close()
+
+	memory, _ := cmd.Flags().GetBool("memory")
+	quiesce, _ := cmd.Flags().GetBool("quiesce")
+	description, _ := cmd.Flags().GetString("description")
+	if err := deployer.CreateSnapshot(provider, vms, args[0], description, memory, quiesce); err != nil {
+		fmt.Println(err.Error())
+		return
+	}
+	fmt.Printf("All nodes have snapshot %s\n", args[0])
+}
+
+func runSnapshotList(cmd *cobra.Command, args []string) {
+	answers, vms, err := readCluster()
+	if err != nil {
+		fmt.Println(err.Error())
+		return
+	}
+	provider, err := driver.NewProvider(answers)
+	if err != nil {
+		fmt.Println(err.Error())
+		return
+	}
+	defer provider.Close()
+
+	snapshots, err := deployer.ListSnapshots(provider, vms)
+	if err != nil {
+		fmt.Println(err.Error())
+		return
+	}
+
+	// one row per snapshot name, with the nodes that have it
+	nodes := map[string][]string{}
+	first := map[string]*model.Snapshot{}
+	names := []string{}
+	for _, vm := range vms.AllNodes() {
+		for _, snapshot := range snapshots[vm.VMName] {
+			if _, ok := first[snapshot.Name]; !ok {
+				first[snapshot.Name] = snapshot
+				names = append(names, snapshot.Name)
+			}
+			nodes[snapshot.Name] = append(nodes[snapshot.Name], vm.VMName)
+		}
+	}
+	sort.Slice(names, func(i, j int) bool {
+		return first[names[i]].CreateTime.Before(first[names[j]].CreateTime)
+	})
+
+	data := [][]string{}
+	total := len(vms.AllNodes())
+	for _, name := range names {
+		snapshot := first[name]
+		coverage := fmt.Sprintf("%d/%d", len(nodes[name]), total)
+		if len(nodes[name]) < total {
+			coverage += " (" + strings.Join(nodes[name], ", ") + ")"
+		}
+		data = append(data, []string{name, snapshot.CreateTime.Local().Format("2006-01-02 15:04:05"), fmt.Sprintf("%t", snapshot.Memory), fmt.Sprintf("%t", snapshot.Quiesced), coverage, snapshot.Description})
+	}
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"NAME", "CREATED", "MEMORY", "QUIESCED", "NODES", "DESCRIPTION"})
+	table.SetBorder(true)
+	table.AppendBulk(data)
+	table.Render()
+}
+
+func runSnapshotRevert(cmd *cobra.Command, args []string) {
+	answers, vms, err := readCluster()
+	if err != nil {
+		fmt.Println(err.Error())
+		return
+	}
+	provider, err := driver.NewProvider(answers)
+	if err != nil {
+		fmt.Println(err.Error())
+		return
+	}
+	defer provider.Close()
+
+	answer := false
+	survey.AskOne(&survey.Confirm{
+		Message: fmt.Sprintf("Are you willing to revert all nodes to %s, changes since then will be lost?", args[0]),
+		Default: false,
+	}, &answer, nil)
+	if !answer {
+		fmt.Println("Bye")
+		return
+	}
+
+	if err := deployer.RevertSnapshot(provider, vms, args[0]); err != nil {
+		fmt.Println(err.Error())
+		return
+	}
+	fmt.Printf("All nodes reverted to %s\n", args[0])
+}
+
+func runSnapshotDelete(cmd *cobra.Command, args []string) {
+	answers, vms, err := readCluster()
+	if err != nil {
+		fmt.Println(err.Error())
+		return
+	}
+	provider, err := driver.NewProvider(answers)
+	if err != nil {
+		fmt.Println(err.Error())
+		return
+	}
+	defer provider.Close()
+
+	if err := deployer.DeleteSnapshot(provider, vms, args[0]); err != nil {
+		fmt.Println(err.Error())
+		return
+	}
+}
@@ -0,0 +1,69 @@
+// Copyright © 2019 Jeff Wu <jeff.wu.junfei@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/jeffwubj/kubev/pkg/kubev/deployer"
+	"github.com/jeffwubj/kubev/pkg/kubev/driver"
+	"github.com/spf13/cobra"
+	survey "gopkg.in/AlecAivazis/survey.v1"
+)
+
+// stopCmd represents the stop command
+var stopCmd = &cobra.Command{
+	Use:   "stop",
+	Short: "Drain and shut down the cluster",
+	Long: `Cordons and drains every worker, shuts down the guest OS of the workers through
+VMware Tools and powers off the master last. Run 'kubev start' to bring it back`,
+	Run: runStop,
+}
+
+func init() {
+	stopCmd.Flags().Bool("force", false, "Shut down workers that cannot be drained")
+	rootCmd.AddCommand(stopCmd)
+}
+
+func runStop(cmd *cobra.Command, args []string) {
+	answers, vms, err := readCluster()
+	if err != nil {
+		fmt.Println(err.Error())
+		return
+	}
+	provider, err := driver.NewProvider(answers)
+	if err != nil {
+		fmt.Println(err.Error())
+		return
+	}
+	defer provider.Close()
+
+	answer := false
+	survey.AskOne(&survey.Confirm{
+		Message: "Are you willing to stop the cluster, its workloads will be unavailable until 'kubev start'?",
+		Default: false,
+	}, &answer, nil)
+	if !answer {
+		fmt.Println("Bye")
+		return
+	}
+
+	force, _ := cmd.Flags().GetBool("force")
+	if err := deployer.StopCluster(provider, answers, vms, force); err != nil {
+		fmt.Println(err.Error())
+		return
+	}
+	fmt.Println("Cluster stopped")
+}
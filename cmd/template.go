@@ -0,0 +1,128 @@
+// Copyright © 2019 Jeff Wu <jeff.wu.junfei@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/jeffwubj/kubev/pkg/kubev/deployer"
+	"github.com/jeffwubj/kubev/pkg/kubev/driver"
+	"github.com/jeffwubj/kubev/pkg/kubev/utils"
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	survey "gopkg.in/AlecAivazis/survey.v1"
+)
+
+// templateCmd represents the template command
+var templateCmd = &cobra.Command{
+	Use:   "template",
+	Short: "Manage VM templates kubev created in vCenter",
+	Long: `kubev imports the VM image once per datacenter as a template named after the OS and kubev version,
+later deployments of the same version reuse it`,
+}
+
+var templateListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List VM templates created by kubev",
+	Long:  ``,
+	Run:   runTemplateList,
+}
+
+var templateDeleteCmd = &cobra.Command{
+	Use:   "delete <name>",
+	Short: "Delete a VM template created by kubev",
+	Long:  ``,
+	Args:  cobra.ExactArgs(1),
+	Run:   runTemplateDelete,
+}
+
+func init() {
+	templateCmd.AddCommand(templateListCmd)
+	templateDeleteCmd.Flags().Bool("force", false, "Delete the template even if linked clones still use it")
+	templateCmd.AddCommand(templateDeleteCmd)
+	rootCmd.AddCommand(templateCmd)
+}
+
+func runTemplateList(cmd *cobra.Command, args []string) {
+	if !utils.FileExists(viper.ConfigFileUsed()) {
+		fmt.Println("There is no config file, run config before managing templates")
+		return
+	}
+
+	answers, err := readConfig()
+	if err != nil {
+		fmt.Println(err.Error())
+		return
+	}
+	provider, err := driver.NewProvider(answers)
+	if err != nil {
+		fmt.Println(err.Error())
+		return
+	}
+	defer provider.Close()
+
+	templates, err := deployer.ListTemplates(provider)
+	if err != nil {
+		fmt.Println(err.Error())
+		return
+	}
+
+	data := [][]string{}
+	for _, template := range templates {
+		data = append(data, []string{template.Name, template.OSVersion, template.KubeVVersion})
+	}
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"NAME", "OS", "KUBEV"})
+	table.SetBorder(true)
+	table.AppendBulk(data)
+	table.Render()
+}
+
+func runTemplateDelete(cmd *cobra.Command, args []string) {
+	if !utils.FileExists(viper.ConfigFileUsed()) {
+		fmt.Println("There is no config file, run config before managing templates")
+		return
+	}
+
+	answers, err := readConfig()
+	if err != nil {
+		fmt.Println(err.Error())
+		return
+	}
+	provider, err := driver.NewProvider(answers)
+	if err != nil {
+		fmt.Println(err.Error())
+		return
+	}
+	defer provider.Close()
+
+	answer := false
+	survey.AskOne(&survey.Confirm{
+		Message: fmt.Sprintf("Are you willing to delete template %s?", args[0]),
+		Default: false,
+	}, &answer, nil)
+	if !answer {
+		fmt.Println("Bye")
+		return
+	}
+
+	force, _ := cmd.Flags().GetBool("force")
+	if err := deployer.DeleteTemplate(provider, args[0], force); err != nil {
+		fmt.Println(err.Error())
+		return
+	}
+}
@@ -15,7 +15,9 @@
 package cmd
 
 import (
+	"bytes"
 	"fmt"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 
@@ -26,6 +28,7 @@ import (
 	"github.com/jeffwubj/kubev/pkg/kubev/utils"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
+	survey "gopkg.in/AlecAivazis/survey.v1"
 )
 
 // useCmd represents the use command
@@ -41,6 +44,7 @@ func init() {
 	rootCmd.AddCommand(useCmd)
 	useCmd.Flags().String("token", "", "token printed in the 'kubev deploy' command")
 	useCmd.MarkFlagRequired("token")
+	useCmd.Flags().String("key", "", "SSH key of the nodes exported by 'kubev info --export-key'")
 	viper.BindPFlags(useCmd.Flags())
 }
 
@@ -57,6 +61,26 @@ func runUse(cmd *cobra.Command, args []string) {
 		return
 	}
 
+	if keyfile := viper.GetString("key"); keyfile != "" {
+		key, err := ioutil.ReadFile(keyfile)
+		if err != nil {
+			fmt.Println(err.Error())
+			return
+		}
+		if !confirmReplaceKey(key) {
+			fmt.Println("Bye")
+			return
+		}
+		if err := utils.SaveSSHKey(string(key)); err != nil {
+			fmt.Println(err.Error())
+			return
+		}
+	}
+	if !utils.FileExists(constants.GetVMPrivateKeyPath()) {
+		fmt.Println("Nodes only accept the kubev SSH key, export it with 'kubev info --export-key <file>' where the cluster was deployed and pass the file with --key")
+		return
+	}
+
 	vmconfig := &model.K8sNode{
 		IP: ip,
 	}
@@ -94,3 +118,18 @@ func runUse(cmd *cobra.Command, args []string) {
 	}
 	fmt.Println("kubectl is ready, enjoy your Kubernetes cluster")
 }
+
+// confirmReplaceKey asks before a different local SSH key is overwritten,
+// the clusters deployed from this host only accept that one.
+func confirmReplaceKey(key []byte) bool {
+	existing, err := ioutil.ReadFile(constants.GetVMPrivateKeyPath())
+	if err != nil || bytes.Equal(bytes.TrimSpace(existing), bytes.TrimSpace(key)) {
+		return true
+	}
+	replace := false
+	survey.AskOne(&survey.Confirm{
+		Message: fmt.Sprintf("%s holds another SSH key, clusters deployed from this host cannot be reached without it, replace it?", constants.GetVMPrivateKeyPath()),
+		Default: false,
+	}, &replace, nil)
+	return replace
+}
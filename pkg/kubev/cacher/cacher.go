@@ -25,7 +25,9 @@ import (
 	"github.com/mholt/archiver"
 )
 
-func CacheAll(kubernetesVersion string) error {
+// CacheAll downloads the Kubernetes binaries of kubernetesVersion, and the
+// OVA if withOVA is set.
+func CacheAll(kubernetesVersion string, withOVA bool) error {
 	for _, binName := range []string{
 		constants.KubeCtlBinaryName,
 		constants.KubeAdmBinaryName,
@@ -40,6 +42,9 @@ func CacheAll(kubernetesVersion string) error {
 		}
 	}
 
+	if !withOVA {
+		return nil
+	}
 	if _, err := Cache(false, constants.PhotonOVAName, constants.DefaultPhotonVersion); err != nil {
 		return err
 	}
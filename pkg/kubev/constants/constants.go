@@ -18,6 +18,7 @@ import (
 	"fmt"
 	"path"
 	"runtime"
+	"time"
 
 	homedir "github.com/mitchellh/go-homedir"
 )
@@ -71,10 +72,68 @@ cp /etc/kubernetes/admin.conf /root/.kube/config &&
 kubectl apply -f "https://cloud.weave.works/k8s/net?k8s-version=$(kubectl version | base64 | tr -d '\n')"
 `
 
+// KubeletExtraArgs pins the address kubelet registers the node with, it
+// would pick the interface of the default route otherwise. The second verb
+// takes more arguments, e.g. KubeletNodeLabels.
+const KubeletExtraArgs = "KUBELET_EXTRA_ARGS=--node-ip=%s%s\n"
+
+// KubeletNodeLabels labels the node when kubelet registers it.
+const KubeletNodeLabels = " --node-labels=%s"
+
 const KubeAdmJoin = "kubeadm token create --print-join-command"
 
 const DeleteWorkNode = "kubectl delete node %s"
 
+const DrainNode = "kubectl drain %s --ignore-daemonsets --delete-local-data --force --timeout=300s"
+
+const UncordonNode = "kubectl uncordon %s"
+
+const APIServerHealth = "kubectl get --raw=/healthz"
+
+// GrowRootFS extends the root partition, which is the last one on the
+// template disk, and its file system to the end of the disk. The disk is in
+// use so sfdisk cannot make the kernel re-read the partition table, that one
+// failure is left to partx, any other fails the node.
+const GrowRootFS = `
+ROOT=$(findmnt -nvo SOURCE /) &&
+DISK=/dev/$(lsblk -no PKNAME $ROOT) &&
+PART=$(cat /sys/class/block/$(basename $ROOT)/partition) &&
+(OUT=$(echo ", +" | sfdisk --no-reread --force -N $PART $DISK 2>&1) ||
+	echo "$OUT" | grep -q "Re-reading the partition table failed" ||
+	{ echo "$OUT" >&2; exit 1; }) &&
+partx -u $DISK &&
+resize2fs $ROOT
+`
+
+// MountDataDisk formats the disk with WWN %[2]s, the vSphere disk UUID
+// without dashes, with label %[1]s unless it is already in fstab, moves what
+// is in %[3]s onto it and mounts it there. A disk with anything mounted from
+// it is never touched.
+const MountDataDisk = `
+set -e
+if ! grep -q "^LABEL=%[1]s " /etc/fstab; then
+  udevadm settle
+  DEV=$(readlink -f /dev/disk/by-id/wwn-0x%[2]s)
+  [ -b "$DEV" ] || { echo "Cannot find disk %[2]s" >&2; exit 1; }
+  if lsblk -no MOUNTPOINT $DEV | grep -q .; then
+    echo "$DEV is in use" >&2
+    exit 1
+  fi
+  if [ -z "$(lsblk -npo NAME $DEV | sed 1d)" ]; then
+    echo ",,L" | sfdisk $DEV
+    udevadm settle
+  fi
+  PART=$(lsblk -npo NAME $DEV | sed -n 2p)
+  blkid $PART || mkfs.ext4 -q -L %[1]s $PART
+  mkdir -p %[3]s /mnt/%[1]s
+  mount $PART /mnt/%[1]s
+  cp -a %[3]s/. /mnt/%[1]s/
+  umount /mnt/%[1]s
+  echo "LABEL=%[1]s %[3]s ext4 defaults 0 2" >> /etc/fstab
+fi
+mountpoint -q %[3]s || mount %[3]s
+`
+
 const DockerService = `
 [Unit]
 Description=Docker Application Container Engine
@@ -115,6 +174,7 @@ const DockerAlias = `#!/bin/sh
 `
 
 const (
+	KubeVVersion                    = "v0.1.0"
 	DefaultPhotonVersion            = "v2.0"
 	KubeAdmBinaryName               = "kubeadm"
 	KubeCtlBinaryName               = "kubectl"
@@ -126,10 +186,9 @@ const (
 	PhotonOVAName                   = "photon.ova"
 	DefaultVMName                   = "Photon"
 	PhotonVMUsername                = "root"
-	PhotonVMOriginalPassword        = "changeme"
-	PhotonVMPassword                = "kubernetes"
 	KubeletServiceFile              = "/etc/systemd/system/kubelet.service"
 	KubeletSystemdConfFile          = "/etc/systemd/system/kubelet.service.d/10-kubeadm.conf"
+	KubeletSysconfigFile            = "/etc/sysconfig/kubelet"
 	DockerServiceFile               = "/usr/lib/systemd/system/docker.service"
 	DefaultVMTemplateName           = "kubev-template"
 	K8sNodesConfigFileName          = "kubev-k8s.json"
@@ -141,6 +200,57 @@ const (
 	DefaultRemoteNetwork            = "VM Network"
 	DefaultKubernetesVersion        = "v1.13.0"
 	DefaultKubernetesWorkderNodeNum = "5"
+	VSphereProvider                 = "vsphere"
+	DefaultProvider                 = VSphereProvider
+	LinkedCloneSnapshotName         = "kubev-linked-clone-base"
+	FullClone                       = "full"
+	LinkedClone                     = "linked"
+	DiskCopy                        = "copy"
+	MasterRole                      = "master"
+	WorkerRole                      = "worker"
+	ClusterIDField                  = "kubev.cluster"
+	RoleField                       = "kubev.role"
+	NodeField                       = "kubev.node"
+	VersionField                    = "kubev.version"
+	ControlPlanePoolName            = "master"
+	DefaultNodePoolName             = "worker"
+	PersistSessionEnv               = "KUBEV_PERSIST_SESSION"
+	PreflightPass                   = "pass"
+	PreflightWarn                   = "warn"
+	PreflightFail                   = "fail"
+	PoweredOn                       = "poweredOn"
+	CSIHardwareVersion              = "vmx-15"
+	CSINICType                      = "vmxnet3"
+	CSISCSIType                     = "pvscsi"
+	SSHChannel                      = "ssh"
+	ToolsChannel                    = "tools"
+	DefaultCommandChannel           = SSHChannel
+	RoundRobinPlacement             = "roundrobin"
+	WeightedPlacement               = "weighted"
+	ZoneLabel                       = "topology.kubernetes.io/zone"
+	LegacyZoneLabel                 = "failure-domain.beta.kubernetes.io/zone"
+	PoweredOff                      = "poweredOff"
+	// GuestShutdownTimeout is how long a guest gets to shut down before
+	// the VM is powered off
+	GuestShutdownTimeout = 5 * time.Minute
+	// GuestIPTimeout is how long VMware Tools gets to report the IP of a
+	// node
+	GuestIPTimeout = 10 * time.Minute
+	// BootstrapTimeout is how long a new node gets to apply its cloud-init
+	// data
+	BootstrapTimeout = 5 * time.Minute
+	// APIServerTimeout is how long start waits for the API server
+	APIServerTimeout = 10 * time.Minute
+	// TemplateDiskSize is the size of the root disk of the OVA in GB
+	TemplateDiskSize = 16
+	// UploadRetries is how often each file of the OVA is tried
+	UploadRetries = 3
+	// TaskPollInterval is how often events --follow looks for finished
+	// tasks
+	TaskPollInterval = 5 * time.Second
+	// GuestPollInterval is how often a command run through VMware Tools is
+	// checked for completion
+	GuestPollInterval = time.Second
 )
 
 func GetHomeFolder() string {
@@ -192,6 +302,16 @@ func GetRemoteVMPublicKeyPath() string {
 	return GetRemoteVMPrivateKeyPath() + ".pub"
 }
 
+// GetVMPasswordPath returns where the root password VMware Tools logs in to
+// the nodes with is kept, the nodes only get its hash.
+func GetVMPasswordPath() string {
+	return path.Join(GetKubeVHomeFolder(), "root_password")
+}
+
+func GetRemoteVMPasswordPath() string {
+	return "/root/.kubev/" + "root_password"
+}
+
 func GetLocalK8sKitFilePath(binaryName, version string) string {
 	if binaryName == DockerBinaryName {
 		return path.Join(GetKubeVHomeFolder(), "cache", binaryName, version, binaryName, binaryName)
@@ -0,0 +1,57 @@
+// Copyright © 2019 Jeff Wu <jeff.wu.junfei@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deployer
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/jeffwubj/kubev/pkg/kubev/constants"
+	"github.com/jeffwubj/kubev/pkg/kubev/driver"
+	"github.com/jeffwubj/kubev/pkg/kubev/model"
+	"k8s.io/minikube/pkg/minikube/assets"
+)
+
+// CommandRunner runs commands in a node and copies files to it.
+type CommandRunner interface {
+	Run(cmd string) error
+	CombinedOutputTo(cmd string, out io.Writer) error
+	CombinedOutput(cmd string) (string, error)
+	Copy(f assets.CopyableFile) error
+	Remove(f assets.CopyableFile) error
+	Close() error
+}
+
+// GetRunner returns the runner of the command channel answers selects, SSH
+// to the node IP unless VMware Tools is chosen.
+func GetRunner(provider driver.Provider, answers *model.Answers, vmconfig *model.K8sNode) (CommandRunner, error) {
+	if answers.CommandChannel != constants.ToolsChannel {
+		runner, _, err := GetSSHRunner(vmconfig)
+		if err != nil {
+			return nil, err
+		}
+		return runner, nil
+	}
+
+	operator, ok := provider.(driver.GuestOperator)
+	if !ok {
+		return nil, fmt.Errorf("The provider cannot run commands without SSH, use the %s command channel", constants.SSHChannel)
+	}
+	g, err := operator.GuestOperations(vmconfig)
+	if err != nil {
+		return nil, err
+	}
+	return NewToolsRunner(g), nil
+}
@@ -16,33 +16,36 @@ package deployer
 
 import (
 	"fmt"
-	"io"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 	"strings"
 
 	"github.com/jeffwubj/kubev/pkg/kubev/constants"
+	"github.com/jeffwubj/kubev/pkg/kubev/driver"
 	"github.com/jeffwubj/kubev/pkg/kubev/model"
 	"github.com/jeffwubj/kubev/pkg/kubev/utils"
-	"github.com/pkg/sftp"
 	"github.com/spf13/viper"
-	"golang.org/x/crypto/ssh"
 	"k8s.io/minikube/pkg/util/kubeconfig"
 )
 
-func UpdateMasterNode(k8snodes *model.K8sNodes) error {
+// kubectlLinkDir is where kubectl of the deployed version is linked to.
+var kubectlLinkDir = "/usr/local/bin/"
+
+func UpdateMasterNode(provider driver.Provider, answers *model.Answers, k8snodes *model.K8sNodes) error {
 	vmconfig := k8snodes.MasterNode
 
-	if err := PrepareVM(vmconfig); err != nil {
+	if err := PrepareVM(provider, answers, vmconfig); err != nil {
 		return err
 	}
 
 	k8sversion := viper.GetString("kubernetesversion")
 
-	runner, c, err := GetSSHRunner(vmconfig)
+	runner, err := GetRunner(provider, answers, vmconfig)
 	if err != nil {
 		return err
 	}
+	defer runner.Close()
 
 	fmt.Println("Install Kubernetes...")
 	output, err := runner.CombinedOutput(constants.KubeAdmInit)
@@ -57,12 +60,12 @@ func UpdateMasterNode(k8snodes *model.K8sNodes) error {
 		}
 	}
 
-	if err := PopuldateKubeConfig(c); err != nil {
+	if err := PopuldateKubeConfig(runner); err != nil {
 		fmt.Println("Failed to write Kuberntes config file")
 		return err
 	}
 
-	symlink := filepath.Join("/usr/local/bin/", constants.KubeCtlBinaryName)
+	symlink := filepath.Join(kubectlLinkDir, constants.KubeCtlBinaryName)
 	os.Remove(symlink)
 	kubectlLocalPath := constants.GetLocalK8sKitFilePath(constants.KubeCtlBinaryName, k8sversion)
 	if err := os.Symlink(kubectlLocalPath, symlink); err != nil {
@@ -87,38 +90,20 @@ func UpdateMasterNode(k8snodes *model.K8sNodes) error {
 	return nil
 }
 
-func PopuldateKubeConfig(c *ssh.Client) error {
+func PopuldateKubeConfig(runner CommandRunner) error {
 	fmt.Println("Populate Kubernetes configure file")
 	oldKubeConfig, err := kubeconfig.ReadConfigOrNew(constants.GetK8sConfigPath())
 	if err != nil {
 		return err
 	}
 
-	client, err := sftp.NewClient(c)
-	if err != nil {
-		return err
-	}
-	defer client.Close()
-
-	srcFile, err := client.Open("/etc/kubernetes/admin.conf")
+	admin, err := runner.CombinedOutput("cat /etc/kubernetes/admin.conf")
 	if err != nil {
 		return err
 	}
 
 	os.MkdirAll(constants.GetK8sConfigFolder(), os.ModePerm)
-	dstFile, err := os.OpenFile(constants.GetK8sTmpConfigPath(), os.O_WRONLY|os.O_TRUNC|os.O_CREATE, os.ModePerm)
-	if err != nil {
-		return err
-	}
-	defer dstFile.Close()
-	_, err = io.Copy(dstFile, srcFile)
-	if err != nil {
-		return err
-	}
-
-	// flush in-memory copy
-	err = dstFile.Sync()
-	if err != nil {
+	if err := ioutil.WriteFile(constants.GetK8sTmpConfigPath(), []byte(admin), os.ModePerm); err != nil {
 		return err
 	}
 
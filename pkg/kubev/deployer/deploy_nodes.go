@@ -16,31 +16,28 @@ package deployer
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/jeffwubj/kubev/pkg/kubev/constants"
+	"github.com/jeffwubj/kubev/pkg/kubev/driver"
 	"github.com/jeffwubj/kubev/pkg/kubev/model"
 	"github.com/jeffwubj/kubev/pkg/kubev/utils"
 	"github.com/spf13/viper"
 )
 
-func DeployNodes(answers *model.Answers) (*model.K8sNodes, error) {
+func DeployNodes(provider driver.Provider, answers *model.Answers) (*model.K8sNodes, error) {
 	if err := generateSSHKey(); err != nil {
 		return nil, err
 	}
-
-	var workderNodes []*model.K8sNode
-
-	worker := "kubev-esx-worker"
-	if answers.IsVCenter {
-		worker = "kubev-vc-worker"
+	if answers.CommandChannel == constants.ToolsChannel {
+		if err := generateToolsPassword(); err != nil {
+			return nil, err
+		}
 	}
 
-	for i := 1; i <= answers.WorkerNodes; i++ {
-		workderNodes = append(workderNodes, &model.K8sNode{
-			MasterNode: false,
-			VMName:     fmt.Sprintf("%s-%d", worker, i),
-			Ready:      false,
-		})
+	clusterID, err := utils.NewClusterID()
+	if err != nil {
+		return nil, err
 	}
 
 	masterName := "kubev-esx-master"
@@ -48,28 +45,50 @@ func DeployNodes(answers *model.Answers) (*model.K8sNodes, error) {
 		masterName = "kubev-vc-master"
 	}
 
+	controlplane, pools := NodePools(answers)
 	k8sNodes := &model.K8sNodes{
 		MasterNode: &model.K8sNode{
+			ClusterID:  clusterID,
 			MasterNode: true,
 			VMName:     masterName,
 			Ready:      false,
+			Pool:       controlplane.Name,
 		},
-		WorkerNodes: workderNodes,
+		ControlPlane: controlplane,
+		NodePools:    pools,
 	}
 
-	_, err := CreateVM(k8sNodes.MasterNode, answers)
+	AssignFailureDomain(answers, k8sNodes, k8sNodes.MasterNode)
+
+	for _, pool := range pools {
+		for i := 0; i < pool.Replicas; i++ {
+			k8sNodes.WorkerNodes = append(k8sNodes.WorkerNodes, NewPoolNode(answers, k8sNodes, pool))
+		}
+	}
+
+	if err := PrepareInventory(provider, answers, k8sNodes); err != nil {
+		return nil, err
+	}
+	// destory can clean up what was created even if the deployment fails
+	utils.SaveK8sNodes(k8sNodes)
+
+	if err := assignStaticIP(k8sNodes.MasterNode, answers, k8sNodes); err != nil {
+		return nil, err
+	}
+
+	err = CreateVM(provider, k8sNodes.MasterNode, controlplane, answers)
 	if err != nil {
 		return nil, err
 	}
 
 	fmt.Printf("%s created\n", k8sNodes.MasterNode.VMName)
 	modify_known_hosts(k8sNodes.MasterNode.IP)
-	if err := ConfigVM(k8sNodes.MasterNode); err != nil {
+	if err := ConfigVM(provider, answers, k8sNodes.MasterNode); err != nil {
 		return nil, err
 	}
 
 	if !k8sNodes.MasterNode.Ready {
-		err = UpdateMasterNode(k8sNodes)
+		err = UpdateMasterNode(provider, answers, k8sNodes)
 		if err != nil {
 			return nil, err
 		}
@@ -77,11 +96,15 @@ func DeployNodes(answers *model.Answers) (*model.K8sNodes, error) {
 
 	// TODO Do it in parallel
 	for _, vm := range k8sNodes.WorkerNodes {
-		if err := DeployWorkderNode(vm, answers, k8sNodes); err != nil {
+		if err := DeployWorkderNode(provider, vm, answers, k8sNodes); err != nil {
 			return nil, err
 		}
 	}
 
+	if err := UpdatePlacement(provider, k8sNodes); err != nil {
+		return nil, err
+	}
+
 	k8sNodes.MasterNode.Ready = true
 
 	for _, vm := range k8sNodes.WorkerNodes {
@@ -90,7 +113,7 @@ func DeployNodes(answers *model.Answers) (*model.K8sNodes, error) {
 
 	utils.SaveK8sNodes(k8sNodes)
 
-	err = UploadConfigToMasterNode(answers, k8sNodes)
+	err = UploadConfigToMasterNode(provider, answers, k8sNodes)
 	if err != nil {
 		return k8sNodes, err
 	}
@@ -98,27 +121,51 @@ func DeployNodes(answers *model.Answers) (*model.K8sNodes, error) {
 	return k8sNodes, nil
 }
 
-func DeployWorkderNode(vmconfig *model.K8sNode, answers *model.Answers, k8sNodes *model.K8sNodes) error {
-	_, err := CreateVM(vmconfig, answers)
+func DeployWorkderNode(provider driver.Provider, vmconfig *model.K8sNode, answers *model.Answers, k8sNodes *model.K8sNodes) error {
+	if err := assignStaticIP(vmconfig, answers, k8sNodes); err != nil {
+		return err
+	}
+	pool := k8sNodes.NodePool(vmconfig.Pool)
+	if pool == nil {
+		return fmt.Errorf("Cannot find node pool %s of %s", vmconfig.Pool, vmconfig.VMName)
+	}
+	err := CreateVM(provider, vmconfig, pool, answers)
 	if err != nil {
 		return err
 	}
 	fmt.Printf("%s created\n", vmconfig.VMName)
 	modify_known_hosts(vmconfig.IP)
-	if err := ConfigVM(vmconfig); err != nil {
+	if err := ConfigVM(provider, answers, vmconfig); err != nil {
+		return err
+	}
+	if err := UpdateWorkerNode(provider, answers, vmconfig, k8sNodes); err != nil {
 		return err
 	}
-	if err := UpdateWorkerNode(vmconfig, k8sNodes); err != nil {
+	return nil
+}
+
+// assignStaticIP allocates an address for vmconfig from the IP pool, nodes
+// keep using DHCP if there is no pool configured.
+func assignStaticIP(vmconfig *model.K8sNode, answers *model.Answers, k8sNodes *model.K8sNodes) error {
+	if answers.IPPool == nil || vmconfig.StaticIP {
+		return nil
+	}
+	ip, err := utils.AllocateIP(answers.IPPool, k8sNodes)
+	if err != nil {
 		return err
 	}
+	vmconfig.IP = ip
+	vmconfig.StaticIP = true
+	fmt.Printf("Allocated %s for %s\n", ip, vmconfig.VMName)
 	return nil
 }
 
-func DeleteWorkerNodeFromKubenretes(vmconfig *model.K8sNode, k8sNodes *model.K8sNodes) error {
-	runner, _, err := GetSSHRunner(k8sNodes.MasterNode)
+func DeleteWorkerNodeFromKubenretes(provider driver.Provider, answers *model.Answers, vmconfig *model.K8sNode, k8sNodes *model.K8sNodes) error {
+	runner, err := GetRunner(provider, answers, k8sNodes.MasterNode)
 	if err != nil {
 		return err
 	}
+	defer runner.Close()
 	err = runner.Run(fmt.Sprintf(constants.DeleteWorkNode, vmconfig.VMName))
 	if err != nil {
 		return err
@@ -127,24 +174,57 @@ func DeleteWorkerNodeFromKubenretes(vmconfig *model.K8sNode, k8sNodes *model.K8s
 }
 
 func setTmpViperToExcludeCredential(answers *model.Answers) {
+	viper.Set("provider", answers.Provider)
 	viper.Set("serverurl", answers.Serverurl)
 	viper.Set("port", answers.Port)
 	viper.Set("username", "github.com/jeffwubj/kubev")
 	viper.Set("password", "github.com/jeffwubj/kubev")
+	viper.Set("insecure", answers.Insecure)
+	viper.Set("thumbprint", answers.Thumbprint)
+	viper.Set("cabundle", answers.CABundle)
 	viper.Set("datacenter", answers.Datacenter)
 	viper.Set("datastore", answers.Datastore)
+	viper.Set("storagepolicy", answers.StoragePolicy)
+	viper.Set("datastorecluster", answers.DatastoreCluster)
 	viper.Set("resourcepool", answers.Resourcepool)
 	viper.Set("folder", answers.Folder)
+	viper.Set("poolallocation", answers.PoolAllocation)
+	viper.Set("hardwareprofile", answers.HardwareProfile)
 	viper.Set("cpu", answers.Cpu)
 	viper.Set("memory", answers.Memory)
 	viper.Set("network", answers.Network)
 	viper.Set("kubernetesVersion", answers.KubernetesVersion)
 	viper.Set("workernodes", answers.WorkerNodes)
 	viper.Set("isvcenter", answers.IsVCenter)
+	viper.Set("linkedclone", answers.LinkedClone)
+	viper.Set("contentlibrary", answers.ContentLibrary)
+	viper.Set("libraryitem", answers.LibraryItem)
+	viper.Set("streamova", answers.StreamOVA)
+	viper.Set("commandchannel", answers.CommandChannel)
+	pool := answers.IPPool
+	if pool == nil {
+		pool = &model.IPPool{}
+	}
+	viper.Set("ippool.cidr", pool.CIDR)
+	viper.Set("ippool.gateway", pool.Gateway)
+	viper.Set("ippool.dns", strings.Join(pool.DNS, ","))
+	viper.Set("ippool.rangestart", pool.RangeStart)
+	viper.Set("ippool.rangeend", pool.RangeEnd)
+	viper.Set("controlplane", answers.ControlPlane)
+	viper.Set("nodepools", answers.NodePools)
+	viper.Set("failuredomains", answers.FailureDomains)
+	viper.Set("domainplacement", answers.DomainPlacement)
 }
 
-func UploadConfigToMasterNode(answers *model.Answers, k8sNodes *model.K8sNodes) error {
-	if err := CopyLocalFileToRemote(k8sNodes.MasterNode, constants.GetK8sNodesConfigFilePath(), constants.GetRemoteK8sNodesConfigFilePath()); err != nil {
+func UploadConfigToMasterNode(provider driver.Provider, answers *model.Answers, k8sNodes *model.K8sNodes) error {
+	runner, err := GetRunner(provider, answers, k8sNodes.MasterNode)
+	if err != nil {
+		fmt.Println("Failed to upload meta data, but cluster has been deployed successfully")
+		return err
+	}
+	defer runner.Close()
+
+	if err := CopyLocalFileToRemote(runner, constants.GetK8sNodesConfigFilePath(), constants.GetRemoteK8sNodesConfigFilePath()); err != nil {
 		fmt.Println("Failed to upload meta data, but cluster has been deployed successfully")
 		return err
 	}
@@ -153,20 +233,27 @@ func UploadConfigToMasterNode(answers *model.Answers, k8sNodes *model.K8sNodes)
 	setTmpViperToExcludeCredential(answers)
 	viper.WriteConfigAs(tmpfile)
 	defer utils.DeleteFile(tmpfile)
-	if err := CopyLocalFileToRemote(k8sNodes.MasterNode, tmpfile, constants.GetRemoteKubeVConfigFilePath()); err != nil {
+	if err := CopyLocalFileToRemote(runner, tmpfile, constants.GetRemoteKubeVConfigFilePath()); err != nil {
 		fmt.Println("Failed to upload meta data, but cluster has been deployed successfully")
 		return err
 	}
 
-	if err := CopyLocalFileToRemote(k8sNodes.MasterNode, constants.GetVMPrivateKeyPath(), constants.GetRemoteVMPrivateKeyPath()); err != nil {
+	if err := CopyLocalFileToRemote(runner, constants.GetVMPrivateKeyPath(), constants.GetRemoteVMPrivateKeyPath()); err != nil {
 		fmt.Println("Failed to upload meta data, but cluster has been deployed successfully")
 		return err
 	}
 
-	if err := CopyLocalFileToRemote(k8sNodes.MasterNode, constants.GetVMPublicKeyPath(), constants.GetRemoteVMPublicKeyPath()); err != nil {
+	if err := CopyLocalFileToRemote(runner, constants.GetVMPublicKeyPath(), constants.GetRemoteVMPublicKeyPath()); err != nil {
 		fmt.Println("Failed to upload meta data, but cluster has been deployed successfully")
 		return err
 	}
 
+	if answers.CommandChannel == constants.ToolsChannel {
+		if err := CopyLocalFileToRemote(runner, constants.GetVMPasswordPath(), constants.GetRemoteVMPasswordPath()); err != nil {
+			fmt.Println("Failed to upload meta data, but cluster has been deployed successfully")
+			return err
+		}
+	}
+
 	return nil
 }
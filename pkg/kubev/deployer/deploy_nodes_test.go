@@ -0,0 +1,163 @@
+// Copyright © 2019 Jeff Wu <jeff.wu.junfei@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deployer
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"testing"
+
+	"github.com/jeffwubj/kubev/pkg/kubev/constants"
+	"github.com/jeffwubj/kubev/pkg/kubev/driver/fake"
+	"github.com/jeffwubj/kubev/pkg/kubev/model"
+	"github.com/spf13/viper"
+)
+
+const testVersion = "v1.13.0"
+
+// TestMain points the home folder, where kubev keeps its keys, kits and
+// configs, to a temporary folder.
+func TestMain(m *testing.M) {
+	home, err := ioutil.TempDir("", "kubev-deployer")
+	if err != nil {
+		panic(err)
+	}
+	os.Setenv("HOME", home)
+	kubectlLinkDir = filepath.Join(home, "bin")
+	os.MkdirAll(kubectlLinkDir, os.ModePerm)
+	os.MkdirAll(constants.GetKubeVHomeFolder(), os.ModePerm)
+	viper.Set("kubernetesversion", testVersion)
+
+	kits := []string{}
+	for _, bin := range []string{constants.KubeAdmBinaryName, constants.KubeletBinaryName, constants.CriCtlBinaryName, constants.GuestKubeCtlBinaryName, constants.KubeCtlBinaryName} {
+		kits = append(kits, constants.GetLocalK8sKitFilePath(bin, testVersion))
+	}
+	for _, bin := range []string{"bridge", "dhcp", "flannel", "host-device", "host-local", "ipvlan", "loopback", "macvlan", "portmap", "ptp", "sample", "tuning", "vlan"} {
+		kits = append(kits, path.Join(constants.GetLocalK8sKitPath(constants.CNIKits, testVersion), bin))
+	}
+	for _, kit := range kits {
+		os.MkdirAll(filepath.Dir(kit), os.ModePerm)
+		if err := ioutil.WriteFile(kit, []byte(filepath.Base(kit)), 0750); err != nil {
+			panic(err)
+		}
+	}
+
+	code := m.Run()
+	os.RemoveAll(home)
+	os.Exit(code)
+}
+
+func testAnswers() *model.Answers {
+	return &model.Answers{
+		Cpu:               2,
+		Memory:            2048,
+		KubernetesVersion: testVersion,
+		WorkerNodes:       2,
+		CommandChannel:    constants.ToolsChannel,
+	}
+}
+
+func TestDeployNodes(t *testing.T) {
+	provider := fake.NewProvider()
+	k8snodes, err := DeployNodes(provider, testAnswers())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(k8snodes.WorkerNodes) != 2 {
+		t.Fatalf("Deployed %d workers, want 2", len(k8snodes.WorkerNodes))
+	}
+	if len(provider.VMs) != 3 {
+		t.Fatalf("Provider has %d VMs, want 3", len(provider.VMs))
+	}
+	if k8snodes.JoinString != fake.JoinCommand {
+		t.Errorf("Join command is %q, want %q", k8snodes.JoinString, fake.JoinCommand)
+	}
+
+	clusterID := k8snodes.MasterNode.ClusterID
+	for _, node := range k8snodes.AllNodes() {
+		if !node.Ready {
+			t.Errorf("%s is not ready", node.VMName)
+		}
+		vm, ok := provider.VMs[node.VMName]
+		if !ok {
+			t.Errorf("%s was not created", node.VMName)
+			continue
+		}
+		if vm.ClusterID != clusterID {
+			t.Errorf("%s belongs to cluster %s, want %s", node.VMName, vm.ClusterID, clusterID)
+		}
+		if _, ok := provider.Files[node.VMName]["/usr/bin/kubeadm"]; !ok {
+			t.Errorf("kubeadm was not copied to %s", node.VMName)
+		}
+	}
+	for _, worker := range k8snodes.WorkerNodes {
+		if !hasCommand(provider.Commands[worker.VMName], fake.JoinCommand) {
+			t.Errorf("%s did not join the cluster", worker.VMName)
+		}
+	}
+
+	master := provider.Files[k8snodes.MasterNode.VMName]
+	for _, file := range []string{constants.GetRemoteK8sNodesConfigFilePath(), constants.GetRemoteVMPrivateKeyPath(), constants.GetRemoteVMPasswordPath()} {
+		if _, ok := master[file]; !ok {
+			t.Errorf("%s was not uploaded to the master", file)
+		}
+	}
+	if _, err := os.Stat(constants.GetK8sConfigPath()); err != nil {
+		t.Errorf("kubeconfig was not written: %s", err.Error())
+	}
+	if _, err := os.Lstat(filepath.Join(kubectlLinkDir, constants.KubeCtlBinaryName)); err != nil {
+		t.Errorf("kubectl was not linked: %s", err.Error())
+	}
+}
+
+func TestDestory(t *testing.T) {
+	provider := fake.NewProvider()
+	k8snodes, err := DeployNodes(provider, testAnswers())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a worker that is missing from the saved node list and a VM of another
+	// cluster
+	missing := &model.K8sNode{ClusterID: k8snodes.MasterNode.ClusterID, VMName: "kubev-missing"}
+	other := &model.K8sNode{ClusterID: "other", VMName: "kubev-other"}
+	for _, node := range []*model.K8sNode{missing, other} {
+		if err := provider.CreateNode(node, k8snodes.NodePools[0]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := Destory(provider, k8snodes); err != nil {
+		t.Fatal(err)
+	}
+	if len(provider.VMs) != 1 {
+		t.Errorf("Provider has %d VMs left, want 1", len(provider.VMs))
+	}
+	if _, ok := provider.VMs[other.VMName]; !ok {
+		t.Errorf("VM %s of another cluster was deleted", other.VMName)
+	}
+}
+
+func hasCommand(commands []string, cmd string) bool {
+	for _, c := range commands {
+		if c == cmd {
+			return true
+		}
+	}
+	return false
+}
@@ -18,8 +18,10 @@ import (
 	"fmt"
 	"io/ioutil"
 	"path"
+	"strings"
 
 	"github.com/jeffwubj/kubev/pkg/kubev/constants"
+	"github.com/jeffwubj/kubev/pkg/kubev/driver"
 	"github.com/jeffwubj/kubev/pkg/kubev/model"
 	"github.com/spf13/viper"
 	"golang.org/x/crypto/ssh"
@@ -27,7 +29,7 @@ import (
 	"k8s.io/minikube/pkg/minikube/assets"
 )
 
-func PrepareVM(vmconfig *model.K8sNode) error {
+func PrepareVM(provider driver.Provider, answers *model.Answers, vmconfig *model.K8sNode) error {
 	fmt.Printf("Prepare k8s node %s...\n", vmconfig.VMName)
 
 	k8sversion := viper.GetString("kubernetesversion")
@@ -35,6 +37,7 @@ func PrepareVM(vmconfig *model.K8sNode) error {
 	files := []assets.CopyableFile{
 		assets.NewMemoryAssetTarget([]byte(constants.KubeletService), constants.KubeletServiceFile, "0640"),
 		assets.NewMemoryAssetTarget([]byte(constants.KubeletSystemd), constants.KubeletSystemdConfFile, "0640"),
+		assets.NewMemoryAssetTarget([]byte(fmt.Sprintf(constants.KubeletExtraArgs, vmconfig.IP, kubeletNodeLabels(vmconfig))), constants.KubeletSysconfigFile, "0640"),
 		// assets.NewMemoryAssetTarget([]byte(constants.DockerService), constants.DockerServiceFile, "0640"),
 	}
 
@@ -65,13 +68,19 @@ func PrepareVM(vmconfig *model.K8sNode) error {
 		files = append(files, binfile)
 	}
 
-	runner, _, err := GetSSHRunner(vmconfig)
+	runner, err := GetRunner(provider, answers, vmconfig)
 	if err != nil {
 		return err
 	}
+	defer runner.Close()
 
 	fmt.Println("Connected to guest.")
 
+	if err := prepareDisks(runner, vmconfig); err != nil {
+		fmt.Println("Failed to prepare disks")
+		return err
+	}
+
 	fmt.Println("Copy files to guest...")
 	for _, f := range files {
 		if err := runner.Copy(f); err != nil {
@@ -109,13 +118,49 @@ func PrepareVM(vmconfig *model.K8sNode) error {
 	return nil
 }
 
-func UpdateWorkerNode(vmconfig *model.K8sNode, k8snodes *model.K8sNodes) error {
-	runner, _, err := GetSSHRunner(vmconfig)
+// prepareDisks grows the root file system and mounts the data disks of
+// vmconfig, services using the mount points are stopped first.
+func prepareDisks(runner CommandRunner, vmconfig *model.K8sNode) error {
+	if vmconfig.DiskSize > 0 {
+		fmt.Println("Grow root file system...")
+		if err := runner.Run(constants.GrowRootFS); err != nil {
+			return err
+		}
+	}
+
+	if len(vmconfig.DataDisks) == 0 {
+		return nil
+	}
+	fmt.Println("Mount data disks...")
+	// units are not installed yet on new nodes
+	err := runner.Run(`
+	for unit in kubelet docker; do
+		if systemctl cat $unit > /dev/null 2>&1; then
+			systemctl stop $unit || exit 1
+		fi
+	done
+	`)
 	if err != nil {
 		return err
 	}
+	for i, disk := range vmconfig.DataDisks {
+		label := fmt.Sprintf("kubev-data%d", i+1)
+		wwn := strings.ToLower(strings.Replace(disk.UUID, "-", "", -1))
+		if err := runner.Run(fmt.Sprintf(constants.MountDataDisk, label, wwn, disk.MountPoint)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
-	if err := PrepareVM(vmconfig); err != nil {
+func UpdateWorkerNode(provider driver.Provider, answers *model.Answers, vmconfig *model.K8sNode, k8snodes *model.K8sNodes) error {
+	runner, err := GetRunner(provider, answers, vmconfig)
+	if err != nil {
+		return err
+	}
+	defer runner.Close()
+
+	if err := PrepareVM(provider, answers, vmconfig); err != nil {
 		return err
 	}
 
@@ -135,26 +180,11 @@ func UpdateWorkerNode(vmconfig *model.K8sNode, k8snodes *model.K8sNodes) error {
 }
 
 func GetSSHRunner(vmconfig *model.K8sNode) (*SSHRunner, *ssh.Client, error) {
-	key, err := ioutil.ReadFile(constants.GetVMPrivateKeyPath())
+	config, err := sshClientConfig()
 	if err != nil {
-		fmt.Println("Failed to SSH private keys")
 		return nil, nil, err
 	}
 
-	privateKey, err := cryptossh.ParsePrivateKey(key)
-	if err != nil {
-		fmt.Println("Failed to parse private keys")
-		return nil, nil, err
-	}
-
-	config := &cryptossh.ClientConfig{
-		User: constants.PhotonVMUsername,
-		Auth: []cryptossh.AuthMethod{
-			cryptossh.PublicKeys(privateKey),
-		},
-		HostKeyCallback: cryptossh.InsecureIgnoreHostKey(),
-	}
-
 	c, err := cryptossh.Dial("tcp", fmt.Sprintf("%s:%d", vmconfig.IP, 22), config)
 	if err != nil {
 		fmt.Println("Failed to diag VM")
@@ -164,20 +194,26 @@ func GetSSHRunner(vmconfig *model.K8sNode) (*SSHRunner, *ssh.Client, error) {
 	return runner, c, nil
 }
 
-func GetSSHRunner2(vmconfig *model.K8sNode) (*SSHRunner, *ssh.Client, error) {
-	config := &cryptossh.ClientConfig{
-		User: constants.PhotonVMUsername,
-		Auth: []cryptossh.AuthMethod{
-			cryptossh.Password(constants.PhotonVMPassword),
-		},
-		HostKeyCallback: cryptossh.InsecureIgnoreHostKey(),
+// sshClientConfig logs in as root with the kubev key, nodes do not accept
+// passwords over SSH.
+func sshClientConfig() (*cryptossh.ClientConfig, error) {
+	key, err := ioutil.ReadFile(constants.GetVMPrivateKeyPath())
+	if err != nil {
+		fmt.Println("Failed to SSH private keys")
+		return nil, err
 	}
 
-	c, err := cryptossh.Dial("tcp", fmt.Sprintf("%s:%d", vmconfig.IP, 22), config)
+	privateKey, err := cryptossh.ParsePrivateKey(key)
 	if err != nil {
-		fmt.Println("Failed to diag VM")
-		return nil, nil, err
+		fmt.Println("Failed to parse private keys")
+		return nil, err
 	}
-	runner := NewSSHRunner(c)
-	return runner, c, nil
+
+	return &cryptossh.ClientConfig{
+		User: constants.PhotonVMUsername,
+		Auth: []cryptossh.AuthMethod{
+			cryptossh.PublicKeys(privateKey),
+		},
+		HostKeyCallback: cryptossh.InsecureIgnoreHostKey(),
+	}, nil
 }
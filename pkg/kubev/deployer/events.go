@@ -0,0 +1,32 @@
+// Copyright © 2019 Jeff Wu <jeff.wu.junfei@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deployer
+
+import (
+	"fmt"
+
+	"github.com/jeffwubj/kubev/pkg/kubev/driver"
+	"github.com/jeffwubj/kubev/pkg/kubev/model"
+)
+
+// Events calls f with the recent events of the VMs of k8snodes, and then
+// with new ones until stop is closed if stop is not nil.
+func Events(provider driver.Provider, k8snodes *model.K8sNodes, count int, stop <-chan struct{}, f func(*model.Event) error) error {
+	source, ok := provider.(driver.EventSource)
+	if !ok {
+		return fmt.Errorf("The provider does not record events")
+	}
+	return source.Events(k8snodes, count, stop, f)
+}
@@ -0,0 +1,154 @@
+// Copyright © 2019 Jeff Wu <jeff.wu.junfei@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deployer
+
+import (
+	"fmt"
+
+	"github.com/jeffwubj/kubev/pkg/kubev/constants"
+	"github.com/jeffwubj/kubev/pkg/kubev/model"
+)
+
+// AssignFailureDomain places node in a failure domain of answers and
+// records it in node, nodes keep the domain they were placed in.
+func AssignFailureDomain(answers *model.Answers, k8sNodes *model.K8sNodes, node *model.K8sNode) {
+	if len(answers.FailureDomains) == 0 || node.FailureDomain != "" {
+		return
+	}
+	counts := map[string]int{}
+	for _, n := range k8sNodes.AllNodes() {
+		if n != node && n.Pool == node.Pool {
+			counts[n.FailureDomain]++
+		}
+	}
+	domain := nextFailureDomain(answers, counts)
+	node.FailureDomain = domain.Name
+	fmt.Printf("Place %s in failure domain %s\n", node.VMName, domain.Name)
+}
+
+// nextFailureDomain returns the domain the next node of a pool goes to,
+// counts holds how many nodes of the pool each domain has. Round robin
+// takes the domain with the fewest nodes, weighted placement the one with
+// the fewest nodes for its weight, ties go to the domain defined first.
+func nextFailureDomain(answers *model.Answers, counts map[string]int) *model.FailureDomain {
+	var next *model.FailureDomain
+	for _, domain := range answers.FailureDomains {
+		if next == nil {
+			next = domain
+			continue
+		}
+		if (counts[domain.Name]+1)*domainWeight(answers, next) < (counts[next.Name]+1)*domainWeight(answers, domain) {
+			next = domain
+		}
+	}
+	return next
+}
+
+func domainWeight(answers *model.Answers, domain *model.FailureDomain) int {
+	if answers.DomainPlacement != constants.WeightedPlacement || domain.Weight <= 0 {
+		return 1
+	}
+	return domain.Weight
+}
+
+// ValidateFailureDomains checks that no failure domain moves nodes with
+// static IPs to another network, their IPs come from the pool of the
+// cluster network.
+func ValidateFailureDomains(answers *model.Answers) error {
+	if answers.IPPool == nil {
+		return nil
+	}
+	for _, domain := range answers.FailureDomains {
+		if domain.Network != "" && domain.Network != answers.Network {
+			return fmt.Errorf("Failure domain %s cannot use network %s, the IP pool is of network %s", domain.Name, domain.Network, answers.Network)
+		}
+	}
+	return nil
+}
+
+func failureDomain(answers *model.Answers, name string) *model.FailureDomain {
+	for _, domain := range answers.FailureDomains {
+		if domain.Name == name {
+			return domain
+		}
+	}
+	return nil
+}
+
+// FailureDomainPool returns pool with the placement of the failure domain
+// of node, pool itself if node is in none.
+func FailureDomainPool(answers *model.Answers, node *model.K8sNode, pool *model.NodePool) (*model.NodePool, error) {
+	if node.FailureDomain == "" {
+		return pool, nil
+	}
+	domain := failureDomain(answers, node.FailureDomain)
+	if domain == nil {
+		return nil, fmt.Errorf("Cannot find failure domain %s of %s", node.FailureDomain, node.VMName)
+	}
+	return domainPool(pool, domain), nil
+}
+
+func domainPool(pool *model.NodePool, domain *model.FailureDomain) *model.NodePool {
+	placed := *pool
+	if resourcepool := domain.ResourcePoolPath(); resourcepool != "" {
+		placed.Resourcepool = resourcepool
+	}
+	if domain.Datastore != "" {
+		// Storage DRS would move the node out of the domain
+		placed.Datastore = domain.Datastore
+		placed.DatastoreCluster = ""
+	}
+	if domain.Network != "" {
+		placed.Network = domain.Network
+	}
+	if domain.NodeSubnet != "" {
+		placed.NodeSubnet = domain.NodeSubnet
+	}
+	return &placed
+}
+
+// splitFailureDomains splits pool into one pool per failure domain holding
+// the replicas placed there, as deploy would place them.
+func splitFailureDomains(answers *model.Answers, pool *model.NodePool) []*model.NodePool {
+	if len(answers.FailureDomains) == 0 {
+		return []*model.NodePool{pool}
+	}
+	counts := map[string]int{}
+	for i := 0; i < pool.Replicas; i++ {
+		counts[nextFailureDomain(answers, counts).Name]++
+	}
+	pools := []*model.NodePool{}
+	for _, domain := range answers.FailureDomains {
+		if counts[domain.Name] == 0 {
+			continue
+		}
+		placed := domainPool(pool, domain)
+		placed.Name = fmt.Sprintf("%s@%s", pool.Name, domain.Name)
+		placed.Replicas = counts[domain.Name]
+		pools = append(pools, placed)
+	}
+	return pools
+}
+
+// kubeletNodeLabels returns the kubelet arguments labelling vmconfig with
+// its zone, nothing if it is in no failure domain. Both zone labels are set,
+// older Kubernetes versions only know the legacy one.
+func kubeletNodeLabels(vmconfig *model.K8sNode) string {
+	if vmconfig.FailureDomain == "" {
+		return ""
+	}
+	labels := fmt.Sprintf("%s=%s,%s=%s", constants.ZoneLabel, vmconfig.FailureDomain, constants.LegacyZoneLabel, vmconfig.FailureDomain)
+	return fmt.Sprintf(constants.KubeletNodeLabels, labels)
+}
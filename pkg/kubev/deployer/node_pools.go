@@ -0,0 +1,132 @@
+// Copyright © 2019 Jeff Wu <jeff.wu.junfei@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deployer
+
+import (
+	"fmt"
+
+	"github.com/jeffwubj/kubev/pkg/kubev/constants"
+	"github.com/jeffwubj/kubev/pkg/kubev/model"
+)
+
+// NodePools returns the control plane and the worker pools of answers with
+// empty fields filled in from the cluster wide settings.
+func NodePools(answers *model.Answers) (*model.NodePool, []*model.NodePool) {
+	controlplane := &model.NodePool{}
+	if answers.ControlPlane != nil {
+		*controlplane = *answers.ControlPlane
+	}
+	controlplane.Name = constants.ControlPlanePoolName
+	controlplane.Replicas = 1
+	fillNodePool(controlplane, answers)
+
+	pools := []*model.NodePool{}
+	for _, p := range answers.NodePools {
+		pool := *p
+		fillNodePool(&pool, answers)
+		pools = append(pools, &pool)
+	}
+	if len(pools) == 0 {
+		pool := &model.NodePool{
+			Name:     constants.DefaultNodePoolName,
+			Replicas: answers.WorkerNodes,
+		}
+		fillNodePool(pool, answers)
+		pools = append(pools, pool)
+	}
+	return controlplane, pools
+}
+
+func fillNodePool(pool *model.NodePool, answers *model.Answers) {
+	if pool.Cpu == 0 {
+		pool.Cpu = answers.Cpu
+	}
+	if pool.Memory == 0 {
+		pool.Memory = answers.Memory
+	}
+	if pool.Datastore == "" {
+		pool.Datastore = answers.Datastore
+	}
+	if pool.StoragePolicy == "" {
+		pool.StoragePolicy = answers.StoragePolicy
+	}
+	if pool.DatastoreCluster == "" {
+		pool.DatastoreCluster = answers.DatastoreCluster
+	}
+	if pool.Network == "" {
+		pool.Network = answers.Network
+	}
+	if pool.Resourcepool == "" {
+		pool.Resourcepool = answers.Resourcepool
+	}
+}
+
+// ResolveNodePools fills in the pools of a cluster deployed before node
+// pools existed, its workers all belong to the default pool.
+func ResolveNodePools(answers *model.Answers, k8sNodes *model.K8sNodes) {
+	controlplane, pools := NodePools(answers)
+	if k8sNodes.ControlPlane == nil {
+		k8sNodes.ControlPlane = controlplane
+	}
+	if len(k8sNodes.NodePools) == 0 {
+		k8sNodes.NodePools = pools
+	}
+	if k8sNodes.MasterNode != nil && k8sNodes.MasterNode.Pool == "" {
+		k8sNodes.MasterNode.Pool = k8sNodes.ControlPlane.Name
+	}
+	for _, node := range k8sNodes.WorkerNodes {
+		if node.Pool == "" {
+			node.Pool = k8sNodes.NodePools[0].Name
+		}
+	}
+	for _, pool := range k8sNodes.NodePools {
+		pool.Replicas = len(k8sNodes.PoolNodes(pool.Name))
+	}
+}
+
+// NewPoolNode returns a worker node of pool named after the first free
+// index in it, placed in a failure domain if answers has any.
+func NewPoolNode(answers *model.Answers, k8sNodes *model.K8sNodes, pool *model.NodePool) *model.K8sNode {
+	prefix := "kubev-esx"
+	if answers.IsVCenter {
+		prefix = "kubev-vc"
+	}
+
+	names := map[string]bool{}
+	for _, node := range k8sNodes.WorkerNodes {
+		names[node.VMName] = true
+	}
+	name := ""
+	for i := 1; ; i++ {
+		name = fmt.Sprintf("%s-%s-%d", prefix, pool.Name, i)
+		if !names[name] {
+			break
+		}
+	}
+
+	clusterID := ""
+	if k8sNodes.MasterNode != nil {
+		clusterID = k8sNodes.MasterNode.ClusterID
+	}
+	node := &model.K8sNode{
+		ClusterID:  clusterID,
+		MasterNode: false,
+		VMName:     name,
+		Ready:      false,
+		Pool:       pool.Name,
+	}
+	AssignFailureDomain(answers, k8sNodes, node)
+	return node
+}
@@ -16,6 +16,7 @@ package deployer
 
 import (
 	"io/ioutil"
+	"os"
 	"path"
 	"regexp"
 
@@ -58,3 +59,17 @@ func generateSSHKey() error {
 	}
 	return nil
 }
+
+// generateToolsPassword creates the root password VMware Tools logs in to the
+// nodes with, only its hash is handed to the nodes.
+func generateToolsPassword() error {
+	if utils.FileExists(constants.GetVMPasswordPath()) {
+		return nil
+	}
+	password, err := utils.NewPassword(24)
+	if err != nil {
+		return err
+	}
+	os.MkdirAll(constants.GetKubeVHomeFolder(), os.ModePerm)
+	return ioutil.WriteFile(constants.GetVMPasswordPath(), []byte(password), 0600)
+}
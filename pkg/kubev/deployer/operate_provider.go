@@ -0,0 +1,232 @@
+// Copyright © 2019 Jeff Wu <jeff.wu.junfei@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deployer
+
+import (
+	"fmt"
+
+	"github.com/jeffwubj/kubev/pkg/kubev/driver"
+	"github.com/jeffwubj/kubev/pkg/kubev/model"
+)
+
+func CreateVM(provider driver.Provider, vmConfig *model.K8sNode, pool *model.NodePool, answers *model.Answers) error {
+	pool, err := FailureDomainPool(answers, vmConfig, pool)
+	if err != nil {
+		return err
+	}
+	return provider.CreateNode(vmConfig, pool)
+}
+
+func Destory(provider driver.Provider, k8snodes *model.K8sNodes) error {
+	nodes := k8snodes.AllNodes()
+	if clusterID := k8snodes.MasterNode.ClusterID; clusterID != "" {
+		if placer, ok := provider.(driver.Placer); ok {
+			if err := placer.RemovePlacement(clusterID); err != nil {
+				return err
+			}
+		}
+		found, err := provider.FindClusterNodes(clusterID)
+		if err != nil {
+			return err
+		}
+		if len(found) > 0 {
+			nodes = found
+		}
+	}
+
+	for _, node := range nodes {
+		if err := provider.DeleteNode(node); err != nil {
+			return err
+		}
+	}
+
+	if preparer, ok := provider.(driver.InventoryPreparer); ok {
+		return preparer.RemoveCreated(k8snodes)
+	}
+	return nil
+}
+
+// Preflight checks that the infrastructure can hold the nodes of answers,
+// pools are checked per failure domain. Providers that cannot check return
+// no checks.
+func Preflight(provider driver.Provider, answers *model.Answers) ([]*model.PreflightCheck, error) {
+	if err := ValidateFailureDomains(answers); err != nil {
+		return nil, err
+	}
+	checker, ok := provider.(driver.PreflightChecker)
+	if !ok {
+		return nil, nil
+	}
+	controlplane, pools := NodePools(answers)
+	placed := []*model.NodePool{}
+	for _, pool := range pools {
+		placed = append(placed, splitFailureDomains(answers, pool)...)
+	}
+	return checker.Preflight(splitFailureDomains(answers, controlplane)[0], placed)
+}
+
+// PrepareInventory creates the VM folder and the resource pools of the
+// template, all node pools and failure domains if they are missing, and
+// records what it created in k8snodes.
+func PrepareInventory(provider driver.Provider, answers *model.Answers, k8snodes *model.K8sNodes) error {
+	preparer, ok := provider.(driver.InventoryPreparer)
+	if !ok {
+		return nil
+	}
+	folders, err := preparer.PrepareFolder()
+	if err != nil {
+		return err
+	}
+	k8snodes.CreatedFolders = append(k8snodes.CreatedFolders, folders...)
+
+	names := []string{answers.Resourcepool}
+	for _, pool := range append([]*model.NodePool{k8snodes.ControlPlane}, k8snodes.NodePools...) {
+		if pool != nil {
+			names = append(names, pool.Resourcepool)
+		}
+	}
+	for _, domain := range answers.FailureDomains {
+		names = append(names, domain.ResourcePoolPath())
+	}
+	prepared := map[string]bool{}
+	for _, name := range names {
+		if prepared[name] {
+			continue
+		}
+		prepared[name] = true
+		pools, err := preparer.PrepareResourcePool(name)
+		if err != nil {
+			return err
+		}
+		k8snodes.CreatedResourcePools = append(k8snodes.CreatedResourcePools, pools...)
+	}
+	return nil
+}
+
+func DestorySingle(provider driver.Provider, k8snode *model.K8sNode) error {
+	return provider.DeleteNode(k8snode)
+}
+
+// UpdatePlacement spreads the nodes of k8snodes where the provider can.
+func UpdatePlacement(provider driver.Provider, k8snodes *model.K8sNodes) error {
+	placer, ok := provider.(driver.Placer)
+	if !ok {
+		return nil
+	}
+	return placer.UpdatePlacement(k8snodes)
+}
+
+func ValidatevSphereAccount(provider driver.Provider) error {
+	return provider.Validate()
+}
+
+func FindMasterNodes(provider driver.Provider) ([]*model.K8sNode, error) {
+	return provider.FindMasterNodes()
+}
+
+func FindMasterNode(provider driver.Provider, clusterID string) (*model.K8sNode, error) {
+	return provider.FindMasterNode(clusterID)
+}
+
+// RecoverClusterNodes rebuilds the worker nodes of k8snodes from the VMs of
+// the cluster in the infrastructure, the node list saved on the master may
+// miss nodes or still have deleted ones.
+func RecoverClusterNodes(provider driver.Provider, k8snodes *model.K8sNodes) error {
+	if k8snodes.MasterNode == nil || k8snodes.MasterNode.ClusterID == "" {
+		return nil
+	}
+	found, err := provider.FindClusterNodes(k8snodes.MasterNode.ClusterID)
+	if err != nil {
+		return err
+	}
+	if found == nil {
+		return nil
+	}
+
+	saved := map[string]*model.K8sNode{}
+	for _, node := range k8snodes.AllNodes() {
+		saved[node.VMName] = node
+	}
+	workers := []*model.K8sNode{}
+	for _, node := range found {
+		if node.MasterNode {
+			k8snodes.MasterNode.Mo = node.Mo
+			continue
+		}
+		if known, ok := saved[node.VMName]; ok {
+			known.Mo = node.Mo
+			workers = append(workers, known)
+			continue
+		}
+		node.IP, err = provider.GetNodeIP(node)
+		if err != nil {
+			return err
+		}
+		workers = append(workers, node)
+	}
+	k8snodes.WorkerNodes = workers
+	return nil
+}
+
+func ListTemplates(provider driver.Provider) ([]*model.Template, error) {
+	manager, ok := provider.(driver.TemplateManager)
+	if !ok {
+		return nil, fmt.Errorf("The provider does not keep templates")
+	}
+	return manager.ListTemplates()
+}
+
+func DeleteTemplate(provider driver.Provider, name string, force bool) error {
+	manager, ok := provider.(driver.TemplateManager)
+	if !ok {
+		return fmt.Errorf("The provider does not keep templates")
+	}
+	return manager.DeleteTemplate(name, force)
+}
+
+// ServerThumbprints returns the SHA-1 and SHA-256 thumbprints of the vCenter
+// or ESX certificate for the user to confirm.
+func ServerThumbprints(answers *model.Answers) (string, string, error) {
+	return driver.ServerThumbprints(answers)
+}
+
+// BootstrapApplied tells whether the VM of node applied its cloud-init data,
+// it is assumed to be applied if the provider cannot tell.
+func BootstrapApplied(provider driver.Provider, node *model.K8sNode) (bool, error) {
+	bootstrapper, ok := provider.(driver.Bootstrapper)
+	if !ok {
+		return true, nil
+	}
+	return bootstrapper.BootstrapApplied(node)
+}
+
+// ClearBootstrap removes the cloud-init userdata of node from the VM.
+func ClearBootstrap(provider driver.Provider, node *model.K8sNode) error {
+	bootstrapper, ok := provider.(driver.Bootstrapper)
+	if !ok {
+		return nil
+	}
+	return bootstrapper.ClearBootstrap(node)
+}
+
+// HardwareMismatches lists how the VM of node differs from the hardware
+// profile of answers, or from the one of the vSphere CSI driver.
+func HardwareMismatches(provider driver.Provider, node *model.K8sNode) ([]string, error) {
+	checker, ok := provider.(driver.HardwareChecker)
+	if !ok {
+		return nil, fmt.Errorf("The provider does not check hardware")
+	}
+	return checker.HardwareMismatches(node)
+}
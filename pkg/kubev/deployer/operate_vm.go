@@ -14,28 +14,28 @@
 package deployer
 
 import (
-	"bytes"
 	"fmt"
 	"io"
-	"io/ioutil"
-	"log"
 	"os"
+	"path"
 	"path/filepath"
 	"strings"
 	"time"
 
-	"github.com/ThomasRooney/gexpect"
 	"github.com/jeffwubj/kubev/pkg/kubev/constants"
+	"github.com/jeffwubj/kubev/pkg/kubev/driver"
 	"github.com/jeffwubj/kubev/pkg/kubev/model"
 	"github.com/pkg/sftp"
 	cryptossh "golang.org/x/crypto/ssh"
+	"k8s.io/minikube/pkg/minikube/assets"
 )
 
-func GetKubeAdmJoinCommand(vmconfig *model.K8sNode) (string, error) {
-	runner, _, err := GetSSHRunner(vmconfig)
+func GetKubeAdmJoinCommand(provider driver.Provider, answers *model.Answers, vmconfig *model.K8sNode) (string, error) {
+	runner, err := GetRunner(provider, answers, vmconfig)
 	if err != nil {
 		return "", err
 	}
+	defer runner.Close()
 	output, err := runner.CombinedOutput(constants.KubeAdmJoin)
 	if err != nil {
 		return "", err
@@ -46,69 +46,89 @@ func GetKubeAdmJoinCommand(vmconfig *model.K8sNode) (string, error) {
 	return output, nil
 }
 
-func ConfigVM(vmconfig *model.K8sNode) error {
-	if !needConfigPhoton(vmconfig.IP) {
-		return nil
+// ConfigVM waits until cloud-init let the kubev SSH key in to the VM of
+// vmconfig, or until VMware Tools runs commands in it, then removes the
+// userdata. The VM got its bootstrap data when it was created.
+func ConfigVM(provider driver.Provider, answers *model.Answers, vmconfig *model.K8sNode) error {
+	fmt.Printf("Wait for cloud-init of %s ...\n", vmconfig.VMName)
+	var err error
+	if answers.CommandChannel == constants.ToolsChannel {
+		err = waitForTools(provider, answers, vmconfig)
+	} else {
+		err = waitForSSH(vmconfig)
 	}
-
-	if err := changePhotonDefaultPassword(vmconfig.IP); err != nil {
-		fmt.Println("change password failed")
-		return err
+	if err != nil {
+		return bootstrapError(provider, answers, vmconfig, err)
 	}
+	return ClearBootstrap(provider, vmconfig)
+}
 
-	// fmt.Printf("change default password of %s:%s succeed\n", vmconfig.VMName, vmconfig.IP)
-
-	if err := configSSHInVM(vmconfig); err != nil {
-		fmt.Println("config ssh failed\n")
-		return err
+// bootstrapError tells a node image without the VMware GuestInfo datasource
+// of cloud-init apart from other reasons err may have.
+func bootstrapError(provider driver.Provider, answers *model.Answers, vmconfig *model.K8sNode, err error) error {
+	applied, checkErr := BootstrapApplied(provider, vmconfig)
+	if checkErr == nil && !applied {
+		return fmt.Errorf("%s did not apply its cloud-init data, the node image needs cloud-init with the VMware GuestInfo datasource: %s", vmconfig.VMName, err.Error())
 	}
-
-	return nil
+	return err
 }
 
-func CopyLocalFileToRemote(vmconfig *model.K8sNode, localpath, remotepath string) error {
-	_, c, err := GetSSHRunner(vmconfig)
+// waitForSSH waits until the kubev SSH key logs in to the VM of vmconfig.
+func waitForSSH(vmconfig *model.K8sNode) error {
+	config, err := sshClientConfig()
 	if err != nil {
 		return err
 	}
-	client, err := sftp.NewClient(c)
-	if err != nil {
-		return err
-	}
-	defer client.Close()
-
-	srcFile, err := os.Open(localpath)
-	if err != nil {
-		log.Fatal(err)
 
+	deadline := time.Now().Add(constants.BootstrapTimeout)
+	for {
+		c, err := cryptossh.Dial("tcp", fmt.Sprintf("%s:%d", vmconfig.IP, 22), config)
+		if err == nil {
+			c.Close()
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("Cannot log in to %s with the kubev SSH key after %s: %s", vmconfig.VMName, constants.BootstrapTimeout, err.Error())
+		}
+		time.Sleep(5 * time.Second)
 	}
-	defer srcFile.Close()
-
-	client.Mkdir(filepath.Dir(remotepath))
-
-	dstFile, err := client.Create(remotepath)
-	if err != nil {
-		log.Fatal(err)
+}
 
+// waitForTools waits until a command can be run in the VM of vmconfig
+// through VMware Tools.
+func waitForTools(provider driver.Provider, answers *model.Answers, vmconfig *model.K8sNode) error {
+	deadline := time.Now().Add(constants.BootstrapTimeout)
+	for {
+		runner, err := GetRunner(provider, answers, vmconfig)
+		if err == nil {
+			err = runner.Run("true")
+		}
+		if err == nil {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("Cannot run commands in %s through VMware Tools after %s: %s", vmconfig.VMName, constants.BootstrapTimeout, err.Error())
+		}
+		time.Sleep(5 * time.Second)
 	}
-	defer dstFile.Close()
+}
 
-	ff, err := ioutil.ReadAll(srcFile)
+func CopyLocalFileToRemote(runner CommandRunner, localpath, remotepath string) error {
+	f, err := assets.NewFileAsset(localpath, path.Dir(remotepath), path.Base(remotepath), "0600")
 	if err != nil {
-		log.Fatal(err)
-
+		return err
 	}
-	dstFile.Write(ff)
-	return nil
+	return runner.Copy(f)
 }
 
 func DownloadKubeCtlConfig(vmconfig *model.K8sNode) error {
-	_, c, err := GetSSHRunner2(vmconfig)
+	runner, _, err := GetSSHRunner(vmconfig)
 	if err != nil {
 		return err
 	}
+	defer runner.Close()
 
-	if err := PopuldateKubeConfig(c); err != nil {
+	if err := PopuldateKubeConfig(runner); err != nil {
 		fmt.Println("Failed to write Kuberntes config file")
 		return err
 	}
@@ -116,7 +136,7 @@ func DownloadKubeCtlConfig(vmconfig *model.K8sNode) error {
 }
 
 func CopyRemoteFileToLocal(vmconfig *model.K8sNode, remotepath, localpath string) error {
-	_, c, err := GetSSHRunner2(vmconfig)
+	_, c, err := GetSSHRunner(vmconfig)
 	if err != nil {
 		return err
 	}
@@ -150,109 +170,3 @@ func CopyRemoteFileToLocal(vmconfig *model.K8sNode, remotepath, localpath string
 
 	return nil
 }
-
-func needConfigPhoton(ipAddress string) bool {
-	err := executeSSHCommand("echo", "root", "kubernetes", ipAddress)
-	if err != nil {
-		return true
-	}
-	return false
-}
-
-func changePhotonDefaultPassword(ipAddress string) error {
-
-	if err := modify_known_hosts(ipAddress); err != nil {
-		return err
-	}
-
-	// TODO below does not work correctly
-	cmd := fmt.Sprintf("ssh -o PubkeyAuthentication=no %s@%s", constants.PhotonVMUsername, ipAddress)
-
-	child, err := gexpect.Spawn(cmd)
-	if err != nil {
-		return err
-	}
-	defer child.Close()
-	timeout := 5 * time.Second
-	if err := child.ExpectTimeout("Are you sure you want to continue connecting (yes/no)?", timeout); err != nil {
-		return err
-	}
-	child.SendLine("yes")
-
-	if err := child.ExpectTimeout("assword:", timeout); err != nil {
-		return err
-	}
-	child.SendLine("changeme")
-
-	if err := child.ExpectTimeout("assword:", timeout); err != nil {
-		return err
-	}
-	child.SendLine("changeme")
-
-	if err := child.ExpectTimeout("assword:", timeout); err != nil {
-		return err
-	}
-	child.SendLine("kubernetes")
-
-	if err := child.ExpectTimeout("assword:", timeout); err != nil {
-		return err
-	}
-	child.SendLine("kubernetes")
-
-	if err := child.ExpectTimeout("#", timeout); err != nil {
-		return err
-	}
-	child.SendLine("exit")
-
-	child.Start()
-	return nil
-}
-
-func configSSHInVM(vmconfig *model.K8sNode) error {
-	// read generated public ssh key
-	keyfh, err := os.Open(constants.GetVMPublicKeyPath())
-	if err != nil {
-		return err
-	}
-	defer keyfh.Close()
-	keycontent, err := ioutil.ReadAll(keyfh)
-	if err != nil {
-		return err
-	}
-	command := fmt.Sprintf("echo '%s' > /%s/.ssh/authorized_keys", string(keycontent), constants.PhotonVMUsername)
-	if err := executeSSHCommand(command, constants.PhotonVMUsername, constants.PhotonVMPassword, vmconfig.IP); err != nil {
-		return err
-	}
-
-	return nil
-}
-
-func executeSSHCommand(command, username, password, ip string) error {
-	config := &cryptossh.ClientConfig{
-		User: username,
-		Auth: []cryptossh.AuthMethod{
-			cryptossh.Password(password),
-		},
-		HostKeyCallback: cryptossh.InsecureIgnoreHostKey(),
-	}
-
-	client, err := cryptossh.Dial("tcp", fmt.Sprintf("%s:%d", ip, 22), config)
-	if err != nil {
-		return err
-	}
-
-	session, err := client.NewSession()
-	if err != nil {
-		return err
-	}
-	defer session.Close()
-
-	var b bytes.Buffer
-	session.Stdout = &b
-
-	if err := session.Run(command); err != nil {
-		return err
-	}
-
-	return nil
-}
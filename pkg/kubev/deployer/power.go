@@ -0,0 +1,116 @@
+// Copyright © 2019 Jeff Wu <jeff.wu.junfei@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deployer
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jeffwubj/kubev/pkg/kubev/constants"
+	"github.com/jeffwubj/kubev/pkg/kubev/driver"
+	"github.com/jeffwubj/kubev/pkg/kubev/model"
+	"github.com/jeffwubj/kubev/pkg/kubev/utils"
+)
+
+// StopCluster drains and shuts down the workers, then the master. A worker
+// that cannot be drained stops the command unless force is set.
+func StopCluster(provider driver.Provider, answers *model.Answers, k8snodes *model.K8sNodes, force bool) error {
+	if k8snodes.MasterNode.PowerState != constants.PoweredOff {
+		runner, err := GetRunner(provider, answers, k8snodes.MasterNode)
+		if err != nil {
+			return err
+		}
+		defer runner.Close()
+
+		for _, vm := range k8snodes.WorkerNodes {
+			if vm.PowerState == constants.PoweredOff {
+				continue
+			}
+			fmt.Printf("Drain %s ...\n", vm.VMName)
+			if err := runner.Run(fmt.Sprintf(constants.DrainNode, vm.VMName)); err != nil {
+				if !force {
+					return fmt.Errorf("Cannot drain %s, use --force to stop anyway: %s", vm.VMName, err.Error())
+				}
+				fmt.Printf("Cannot drain %s, stop it anyway: %s\n", vm.VMName, err.Error())
+			}
+		}
+	}
+
+	for _, vm := range append(k8snodes.WorkerNodes, k8snodes.MasterNode) {
+		if err := provider.ShutdownNode(vm); err != nil {
+			return err
+		}
+		vm.PowerState = constants.PoweredOff
+		utils.SaveK8sNodes(k8snodes)
+	}
+	return nil
+}
+
+// StartCluster powers on the master and waits for the API server, then
+// powers on and uncordons the workers.
+func StartCluster(provider driver.Provider, answers *model.Answers, k8snodes *model.K8sNodes) error {
+	if err := startNode(provider, k8snodes, k8snodes.MasterNode); err != nil {
+		return err
+	}
+
+	fmt.Println("Wait for the API server ...")
+	runner, err := GetRunner(provider, answers, k8snodes.MasterNode)
+	if err != nil {
+		return err
+	}
+	defer runner.Close()
+	deadline := time.Now().Add(constants.APIServerTimeout)
+	for {
+		output, err := runner.CombinedOutput(constants.APIServerHealth)
+		if err == nil && strings.TrimSpace(output) == "ok" {
+			break
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("API server is not ready after %s", constants.APIServerTimeout)
+		}
+		time.Sleep(5 * time.Second)
+	}
+
+	for _, vm := range k8snodes.WorkerNodes {
+		if err := startNode(provider, k8snodes, vm); err != nil {
+			return err
+		}
+		fmt.Printf("Uncordon %s ...\n", vm.VMName)
+		if err := runner.Run(fmt.Sprintf(constants.UncordonNode, vm.VMName)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// startNode powers on vm and waits for its IP, which may have changed if it
+// came from DHCP.
+func startNode(provider driver.Provider, k8snodes *model.K8sNodes, vm *model.K8sNode) error {
+	if err := provider.PowerOnNode(vm); err != nil {
+		return err
+	}
+	ip, err := provider.GetNodeIP(vm)
+	if err != nil {
+		return err
+	}
+	if ip != vm.IP {
+		fmt.Printf("%s came back with IP %s instead of %s\n", vm.VMName, ip, vm.IP)
+		vm.IP = ip
+	}
+	vm.PowerState = constants.PoweredOn
+	utils.SaveK8sNodes(k8snodes)
+	return nil
+}
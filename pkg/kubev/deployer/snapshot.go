@@ -0,0 +1,161 @@
+// Copyright © 2019 Jeff Wu <jeff.wu.junfei@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deployer
+
+import (
+	"fmt"
+
+	"github.com/jeffwubj/kubev/pkg/kubev/driver"
+	"github.com/jeffwubj/kubev/pkg/kubev/model"
+)
+
+// CreateSnapshot snapshots every node of k8snodes as name, the snapshots
+// taken so far are deleted again if one node fails so the cluster never has
+// a partial snapshot.
+func CreateSnapshot(provider driver.Provider, k8snodes *model.K8sNodes, name, description string, memory, quiesce bool) error {
+	snapshotter, err := snapshotterOf(provider)
+	if err != nil {
+		return err
+	}
+
+	for _, node := range k8snodes.AllNodes() {
+		snapshots, err := snapshotter.ListSnapshots(node)
+		if err != nil {
+			return err
+		}
+		for _, snapshot := range snapshots {
+			if snapshot.Name == name {
+				return fmt.Errorf("%s already has a snapshot called %s", node.VMName, name)
+			}
+		}
+	}
+
+	taken := []*model.K8sNode{}
+	for _, node := range k8snodes.AllNodes() {
+		if err := snapshotter.CreateSnapshot(node, name, description, memory, quiesce); err != nil {
+			for _, node := range taken {
+				if err := snapshotter.DeleteSnapshot(node, name); err != nil {
+					fmt.Printf("Failed to delete snapshot %s of %s: %s\n", name, node.VMName, err.Error())
+				}
+			}
+			return err
+		}
+		taken = append(taken, node)
+	}
+	return nil
+}
+
+// ListSnapshots returns the snapshots of every node by node name.
+func ListSnapshots(provider driver.Provider, k8snodes *model.K8sNodes) (map[string][]*model.Snapshot, error) {
+	snapshotter, err := snapshotterOf(provider)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshots := map[string][]*model.Snapshot{}
+	for _, node := range k8snodes.AllNodes() {
+		list, err := snapshotter.ListSnapshots(node)
+		if err != nil {
+			return nil, err
+		}
+		snapshots[node.VMName] = list
+	}
+	return snapshots, nil
+}
+
+// RevertSnapshot restores every node to snapshot name, then powers on the
+// master first so workers find the API server when they come back.
+func RevertSnapshot(provider driver.Provider, k8snodes *model.K8sNodes, name string) error {
+	snapshotter, err := snapshotterOf(provider)
+	if err != nil {
+		return err
+	}
+
+	snapshots, err := ListSnapshots(provider, k8snodes)
+	if err != nil {
+		return err
+	}
+	for _, node := range k8snodes.AllNodes() {
+		if !hasSnapshot(snapshots[node.VMName], name) {
+			return fmt.Errorf("%s has no snapshot called %s", node.VMName, name)
+		}
+	}
+
+	for _, node := range k8snodes.AllNodes() {
+		if err := snapshotter.RevertSnapshot(node, name); err != nil {
+			return err
+		}
+	}
+
+	for _, node := range k8snodes.AllNodes() {
+		if err := provider.PowerOnNode(node); err != nil {
+			return err
+		}
+		ip, err := provider.GetNodeIP(node)
+		if err != nil {
+			return err
+		}
+		if ip != node.IP {
+			fmt.Printf("%s came back with IP %s instead of %s\n", node.VMName, ip, node.IP)
+		}
+	}
+	return nil
+}
+
+// DeleteSnapshot deletes snapshot name of every node that has it.
+func DeleteSnapshot(provider driver.Provider, k8snodes *model.K8sNodes, name string) error {
+	snapshotter, err := snapshotterOf(provider)
+	if err != nil {
+		return err
+	}
+
+	snapshots, err := ListSnapshots(provider, k8snodes)
+	if err != nil {
+		return err
+	}
+	found := false
+	for _, node := range k8snodes.AllNodes() {
+		if !hasSnapshot(snapshots[node.VMName], name) {
+			continue
+		}
+		found = true
+		if err := snapshotter.DeleteSnapshot(node, name); err != nil {
+			return err
+		}
+	}
+	if !found {
+		return fmt.Errorf("There is no snapshot called %s", name)
+	}
+	return nil
+}
+
+// snapshotterOf returns provider as a Snapshotter, or an error if it cannot
+// snapshot nodes.
+func snapshotterOf(provider driver.Provider) (driver.Snapshotter, error) {
+	snapshotter, ok := provider.(driver.Snapshotter)
+	if !ok {
+		return nil, fmt.Errorf("The provider does not support snapshots")
+	}
+	return snapshotter, nil
+}
+
+func hasSnapshot(snapshots []*model.Snapshot, name string) bool {
+	for _, snapshot := range snapshots {
+		if snapshot.Name == name {
+			return true
+		}
+	}
+	return false
+}
@@ -129,6 +129,11 @@ func (s *SSHRunner) Copy(f assets.CopyableFile) error {
 	return nil
 }
 
+// Close closes the SSH connection.
+func (s *SSHRunner) Close() error {
+	return s.c.Close()
+}
+
 func getDeleteFileCommand(f assets.CopyableFile) string {
 	return fmt.Sprintf("rm %s", filepath.Join(f.GetTargetDir(), f.GetTargetName()))
 }
@@ -0,0 +1,98 @@
+// Copyright © 2019 Jeff Wu <jeff.wu.junfei@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deployer
+
+import (
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+
+	"github.com/jeffwubj/kubev/pkg/kubev/driver"
+	"github.com/pkg/errors"
+	"k8s.io/minikube/pkg/minikube/assets"
+)
+
+// ToolsRunner runs commands through VMware Tools, vCenter or ESX relays them
+// so the node does not have to be reachable from this host.
+//
+// It implements the CommandRunner interface.
+type ToolsRunner struct {
+	g driver.GuestOperations
+}
+
+// NewToolsRunner returns a new ToolsRunner that will run commands
+// through the guest operations provided.
+func NewToolsRunner(g driver.GuestOperations) *ToolsRunner {
+	return &ToolsRunner{g}
+}
+
+// Remove runs a command to delete a file on the remote.
+func (t *ToolsRunner) Remove(f assets.CopyableFile) error {
+	return t.Run(getDeleteFileCommand(f))
+}
+
+// Run starts a command on the remote and waits for it to return.
+func (t *ToolsRunner) Run(cmd string) error {
+	_, err := t.CombinedOutput(cmd)
+	return err
+}
+
+// CombinedOutputTo runs the command and stores both command
+// output and error to out.
+func (t *ToolsRunner) CombinedOutputTo(cmd string, out io.Writer) error {
+	b, err := t.CombinedOutput(cmd)
+	if err != nil {
+		return errors.Wrapf(err, "running command: %s\n.", cmd)
+	}
+	_, err = out.Write([]byte(b))
+	return err
+}
+
+// CombinedOutput runs the command on the remote and returns its combined
+// standard output and standard error.
+func (t *ToolsRunner) CombinedOutput(cmd string) (string, error) {
+	code, b, err := t.g.Run(cmd)
+	if err != nil {
+		return "", errors.Wrapf(err, "running command: %s", cmd)
+	}
+	if code != 0 {
+		return "", errors.Errorf("running command: %s\n, exit status: %d, output: %s", cmd, code, string(b))
+	}
+	return string(b), nil
+}
+
+// Copy copies a file to the remote through VMware Tools.
+func (t *ToolsRunner) Copy(f assets.CopyableFile) error {
+	mkdirCmd := fmt.Sprintf("mkdir -p %s", f.GetTargetDir())
+	if err := t.Run(mkdirCmd); err != nil {
+		return errors.Wrapf(err, "Error running command: %s", mkdirCmd)
+	}
+
+	mode, err := strconv.ParseInt(f.GetPermissions(), 8, 64)
+	if err != nil {
+		return errors.Wrapf(err, "Error parsing permissions: %s", f.GetPermissions())
+	}
+	target := path.Join(f.GetTargetDir(), f.GetTargetName())
+	if err := t.g.Upload(f, int64(f.GetLength()), target, mode); err != nil {
+		return errors.Wrapf(err, "Error uploading %s", target)
+	}
+	return nil
+}
+
+// Close does nothing, the connection belongs to the provider.
+func (t *ToolsRunner) Close() error {
+	return nil
+}
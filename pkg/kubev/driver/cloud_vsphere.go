@@ -17,22 +17,819 @@ package driver
 import (
 	"context"
 	"fmt"
+	"io/ioutil"
 	"net/url"
+	"path"
+	"strings"
 
+	"github.com/jeffwubj/kubev/pkg/kubev/constants"
 	"github.com/jeffwubj/kubev/pkg/kubev/model"
+	"github.com/jeffwubj/kubev/pkg/kubev/utils"
 	"github.com/vmware/govmomi"
+	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/ovf"
+	"github.com/vmware/govmomi/property"
+	"github.com/vmware/govmomi/session"
+	"github.com/vmware/govmomi/view"
+	"github.com/vmware/govmomi/vim25"
+	"github.com/vmware/govmomi/vim25/soap"
+	"github.com/vmware/govmomi/vim25/types"
 )
 
-func NewClient(ctx context.Context, answers *model.Answers) (*govmomi.Client, error) {
-	serverurl := url.URL{
+func init() {
+	RegisterProvider(constants.VSphereProvider, newVSphereProvider)
+}
+
+func serverURL(answers *model.Answers) *url.URL {
+	return &url.URL{
 		Scheme: "https",
 		Path:   "sdk",
 		User:   url.UserPassword(answers.Username, answers.Password),
 		Host:   fmt.Sprintf("%s:%d", answers.Serverurl, answers.Port),
 	}
-	c, err := govmomi.NewClient(ctx, &serverurl, true)
+}
+
+// NewClient logs in to the vCenter or ESX in answers, its certificate must
+// match the pinned thumbprint or be signed by the CA bundle unless
+// answers.Insecure is set.
+func NewClient(ctx context.Context, answers *model.Answers) (*govmomi.Client, error) {
+	serverurl := serverURL(answers)
+	soapClient := soap.NewClient(serverurl, answers.Insecure)
+	if err := configureTLS(soapClient, answers); err != nil {
+		return nil, err
+	}
+
+	vimClient, err := vim25.NewClient(ctx, soapClient)
 	if err != nil {
 		return nil, err
 	}
+
+	c := &govmomi.Client{
+		Client:         vimClient,
+		SessionManager: session.NewManager(vimClient),
+	}
+	if err := c.Login(ctx, serverurl.User); err != nil {
+		return nil, err
+	}
 	return c, nil
 }
+
+// vSphere can do everything kubev asks of a provider.
+var (
+	_ Bootstrapper      = &vsphereProvider{}
+	_ Placer            = &vsphereProvider{}
+	_ PreflightChecker  = &vsphereProvider{}
+	_ InventoryPreparer = &vsphereProvider{}
+	_ Snapshotter       = &vsphereProvider{}
+	_ EventSource       = &vsphereProvider{}
+	_ HardwareChecker   = &vsphereProvider{}
+	_ GuestOperator     = &vsphereProvider{}
+	_ TemplateManager   = &vsphereProvider{}
+)
+
+// vsphereProvider deploys Kubernetes nodes to a vCenter or a standalone ESX.
+type vsphereProvider struct {
+	answers *model.Answers
+
+	// ctx is cancelled by Close, every operation of the provider uses it
+	ctx    context.Context
+	cancel context.CancelFunc
+	client *govmomi.Client
+	cache  map[string]object.Reference
+	// policies maps storage policy names to their IDs
+	policies map[string]string
+	// library is the REST session for the Content Library, nil until it
+	// is needed
+	library *libraryClient
+}
+
+func newVSphereProvider(answers *model.Answers) (Provider, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &vsphereProvider{
+		answers:  answers,
+		ctx:      ctx,
+		cancel:   cancel,
+		cache:    map[string]object.Reference{},
+		policies: map[string]string{},
+	}, nil
+}
+
+func (p *vsphereProvider) Validate() error {
+	// always log in, a persisted session says nothing about the password
+	client, err := NewClient(p.ctx, p.answers)
+	if err != nil {
+		return err
+	}
+	if err := p.saveClient(client); err != nil {
+		fmt.Printf("Failed to save vSphere session: %s\n", err.Error())
+	}
+	p.client = client
+
+	p.answers.IsVCenter = client.IsVC()
+
+	return nil
+}
+
+func (p *vsphereProvider) deployOVA(ctx context.Context, client *govmomi.Client, finder *find.Finder, targetpath string, pool *model.NodePool) (*object.VirtualMachine, error) {
+	answers := p.answers
+
+	datastore, err := p.datastore(finder, pool.Datastore)
+	if err != nil {
+		return nil, err
+	}
+
+	vm, err := finder.VirtualMachine(ctx, targetpath)
+	if err == nil {
+		return vm, nil
+	}
+
+	var resourcepool *object.ResourcePool
+
+	if answers.IsVCenter {
+		resourcepool, err = p.resourcePool(finder, pool.Resourcepool)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		resourcepool, err = finder.ResourcePoolOrDefault(ctx, "")
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	folder, err := p.folder(finder, p.getVMFolder())
+	if err != nil {
+		return nil, err
+	}
+
+	archive, err := p.ovaArchive(ctx, targetpath)
+	if err != nil {
+		return nil, err
+	}
+	r, _, err := archive.Open("*.ovf")
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	o, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var networks []types.OvfNetworkMapping
+
+	network, err := p.network(finder, pool.Network)
+	if err != nil {
+		return nil, err
+	}
+
+	networks = append(networks, types.OvfNetworkMapping{
+		Name:    pool.Network,
+		Network: network.Reference(),
+	})
+
+	cisp := types.OvfCreateImportSpecParams{
+		DiskProvisioning:   "",
+		EntityName:         path.Base(targetpath),
+		IpAllocationPolicy: "",
+		IpProtocol:         "",
+		OvfManagerCommonParams: types.OvfManagerCommonParams{
+			DeploymentOption: "",
+			Locale:           "US"},
+		PropertyMapping: nil,
+		NetworkMapping:  networks,
+	}
+
+	m := ovf.NewManager(client.Client)
+	spec, err := m.CreateImportSpec(ctx, string(o), resourcepool, datastore, cisp)
+	if err != nil {
+		return nil, err
+	}
+
+	lease, err := resourcepool.ImportVApp(ctx, spec.ImportSpec, folder, nil)
+	if err != nil {
+		return nil, err
+	}
+	info, err := lease.Wait(ctx, spec.FileItem)
+	if err != nil {
+		abortImport(ctx, finder, lease, targetpath, err)
+		return nil, err
+	}
+	if err := uploadLease(ctx, lease, info, archive); err != nil {
+		abortImport(ctx, finder, lease, targetpath, err)
+		return nil, err
+	}
+
+	vm, err = finder.VirtualMachine(ctx, targetpath)
+	if err != nil {
+		return nil, err
+	}
+
+	vmConfigSpec := types.VirtualMachineConfigSpec{}
+	vmConfigSpec.NumCPUs = int32(pool.Cpu)
+	vmConfigSpec.MemoryMB = int64(pool.Memory)
+	task, err := vm.Reconfigure(ctx, vmConfigSpec)
+	if err != nil {
+		return nil, err
+	}
+	err = task.Wait(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return vm, nil
+}
+
+func (p *vsphereProvider) CreateNode(vmConfig *model.K8sNode, pool *model.NodePool) error {
+	answers := p.answers
+
+	if vmConfig.StaticIP && !answers.IsVCenter {
+		return fmt.Errorf("Static IP for %s needs guest customization, which is only available in vCenter", vmConfig.VMName)
+	}
+
+	ctx := p.ctx
+	client, err := p.connect()
+	if err != nil {
+		return err
+	}
+	finder := find.NewFinder(client.Client, true)
+	datacenter, err := p.datacenter(finder)
+	if err != nil {
+		return err
+	}
+
+	finder.SetDatacenter(datacenter)
+
+	template, err := p.templateVM(ctx, client, finder, datacenter)
+	if err != nil {
+		return err
+	}
+
+	if vmConfig.StaticIP {
+		if err := checkStaticIPNIC(ctx, template, answers.HardwareProfile); err != nil {
+			return err
+		}
+	}
+
+	datastore, err := p.datastore(finder, pool.Datastore)
+	if err != nil {
+		return err
+	}
+
+	var resourcepool *object.ResourcePool
+	if answers.IsVCenter {
+		resourcepool, err = p.resourcePool(finder, pool.Resourcepool)
+		if err != nil {
+			return err
+		}
+	} else {
+		host, err := finder.DefaultHostSystem(ctx)
+		if err != nil {
+			return err
+		}
+		resourcepool, err = host.ResourcePool(ctx)
+		if err != nil {
+			return err
+		}
+	}
+
+	clonedVM, err := finder.VirtualMachine(ctx, path.Join(p.getVMFolder(), vmConfig.VMName))
+	if err != nil {
+		if answers.IsVCenter { // vCenter
+			vm := template
+
+			configSpecs := []types.BaseVirtualDeviceConfigSpec{}
+
+			folder, err := p.folder(finder, p.getVMFolder())
+			if err != nil {
+				return err
+			}
+
+			datastore, err = p.nodeDatastore(ctx, client, finder, vm, folder, resourcepool, pool, vmConfig.VMName)
+			if err != nil {
+				return err
+			}
+			profile, err := p.storageProfile(ctx, client, pool)
+			if err != nil {
+				return err
+			}
+
+			folderref := folder.Reference()
+			resourcepoolref := resourcepool.Reference()
+			datastoreref := datastore.Reference()
+
+			relocateSpec := types.VirtualMachineRelocateSpec{
+				DeviceChange: configSpecs,
+				Folder:       &folderref,
+				Pool:         &resourcepoolref,
+				Datastore:    &datastoreref,
+				Profile:      profile,
+			}
+			if profile != nil {
+				relocateSpec.Disk, err = diskLocators(ctx, vm, datastoreref, profile)
+				if err != nil {
+					return err
+				}
+			}
+
+			if !answers.IsVCenter {
+				host, err := finder.DefaultHostSystem(ctx)
+				if err != nil {
+					return err
+				}
+				hostref := host.Reference()
+				relocateSpec.Host = &hostref
+				relocateSpec.DiskMoveType = string(types.VirtualMachineRelocateDiskMoveOptionsMoveAllDiskBackingsAndAllowSharing)
+			}
+
+			cloneSpec := &types.VirtualMachineCloneSpec{
+				PowerOn:  false,
+				Template: false,
+			}
+			cloneSpec.Location = relocateSpec
+
+			if vmConfig.StaticIP {
+				customization, err := staticIPCustomization(vmConfig, answers.IPPool)
+				if err != nil {
+					return err
+				}
+				cloneSpec.Customization = customization
+			}
+
+			vmConfig.CloneMode = constants.FullClone
+			if answers.LinkedClone && pool.DiskSize > 0 {
+				// the child disk of a linked clone cannot be extended
+				fmt.Printf("Pool %s resizes the disk, use full clone for %s\n", pool.Name, vmConfig.VMName)
+			} else if answers.LinkedClone {
+				snapshot, err := prepareLinkedClone(ctx, vm, datastore)
+				if err != nil {
+					fmt.Printf("Linked clone is not available for %s, use full clone: %s\n", vmConfig.VMName, err.Error())
+				} else {
+					linkedSpec := *cloneSpec
+					linkedSpec.Snapshot = snapshot
+					linkedSpec.Location.DiskMoveType = string(types.VirtualMachineRelocateDiskMoveOptionsCreateNewChildDiskBacking)
+					fmt.Printf("Linked clone %s ...\n", vmConfig.VMName)
+					if err := cloneVM(ctx, vm, folder, vmConfig.VMName, linkedSpec); err != nil {
+						fmt.Printf("Linked clone %s failed, use full clone: %s\n", vmConfig.VMName, err.Error())
+					} else {
+						vmConfig.CloneMode = constants.LinkedClone
+					}
+				}
+			}
+
+			if vmConfig.CloneMode == constants.FullClone {
+				fmt.Printf("Clone %s ...\n", vmConfig.VMName)
+				if err := cloneVM(ctx, vm, folder, vmConfig.VMName, *cloneSpec); err != nil {
+					return err
+				}
+			}
+
+			clonedVM, err = finder.VirtualMachine(ctx, path.Join(p.getVMFolder(), vmConfig.VMName))
+			if err != nil {
+				return err
+			}
+		} else { // ESX
+			folder, err := p.folder(finder, p.getVMFolder())
+			if err != nil {
+				return err
+			}
+			clonedVM, err = copyVM(ctx, client, datacenter, template, folder, resourcepool, datastore, vmConfig.VMName)
+			if err != nil {
+				return err
+			}
+			vmConfig.CloneMode = constants.DiskCopy
+		}
+
+	}
+
+	if err := powerOffVM(ctx, clonedVM, vmConfig.VMName); err != nil {
+		return err
+	}
+
+	if answers.IsVCenter {
+		if err := tagVM(ctx, client, clonedVM, vmConfig); err != nil {
+			return err
+		}
+	}
+
+	if err := upgradeHardware(ctx, clonedVM, vmConfig.VMName, answers.HardwareProfile); err != nil {
+		return err
+	}
+
+	fmt.Printf("Reconfigure %s ...\n", vmConfig.VMName)
+	profile, err := p.storageProfile(ctx, client, pool)
+	if err != nil {
+		return err
+	}
+	deviceChange, err := p.poolDeviceChange(ctx, finder, clonedVM, pool, datastore, profile)
+	if err != nil {
+		return err
+	}
+	vmConfigSpec := types.VirtualMachineConfigSpec{}
+	vmConfigSpec.NumCPUs = int32(pool.Cpu)
+	vmConfigSpec.MemoryMB = int64(pool.Memory)
+	vmConfigSpec.DeviceChange = deviceChange
+	bootstrap, err := bootstrapExtraConfig(answers, vmConfig)
+	if err != nil {
+		return err
+	}
+	vmConfigSpec.ExtraConfig = append(hardwareExtraConfig(answers.HardwareProfile), bootstrap...)
+	if len(pool.DataDisks) > 0 {
+		// the guest only sees the serials of disks, which are their UUIDs,
+		// with disk.EnableUUID
+		vmConfigSpec.ExtraConfig = append(vmConfigSpec.ExtraConfig, &types.OptionValue{Key: "disk.EnableUUID", Value: "TRUE"})
+	}
+	task, err := clonedVM.Reconfigure(ctx, vmConfigSpec)
+	if err != nil {
+		return err
+	}
+	_, err = task.WaitForResult(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	dataDisks, err := nodeDataDisks(ctx, clonedVM, pool)
+	if err != nil {
+		return err
+	}
+
+	if err := powerOnVM(ctx, clonedVM, vmConfig.VMName); err != nil {
+		return err
+	}
+
+	fmt.Printf("Wait IP for %s ...\n", vmConfig.VMName)
+	ip := vmConfig.IP
+	if vmConfig.StaticIP {
+		err = waitForGuestIP(ctx, clonedVM, ip)
+	} else {
+		ip, err = waitForNodeIP(ctx, clonedVM, pool.Network, pool.NodeSubnet)
+	}
+	if err != nil {
+		return err
+	}
+
+	vmConfig.NodeNetwork = pool.Network
+	vmConfig.NodeSubnet = pool.NodeSubnet
+	vmConfig.DiskSize = pool.DiskSize
+	vmConfig.DataDisks = dataDisks
+	vmConfig.DatacenterName = datacenter.Name()
+	vmConfig.DatastoreName = datastore.Name()
+	vmConfig.StoragePolicy = pool.StoragePolicy
+	vmConfig.FolderPath = clonedVM.InventoryPath
+	vmConfig.IP = ip
+	vmConfig.Mo = clonedVM.Reference().String()
+
+	return nil
+}
+
+func (p *vsphereProvider) PowerOnNode(k8snode *model.K8sNode) error {
+	ctx := p.ctx
+	client, err := p.connect()
+	if err != nil {
+		return err
+	}
+
+	vm, err := p.nodeVM(ctx, client, k8snode)
+	if err != nil {
+		return err
+	}
+
+	return powerOnVM(ctx, vm, k8snode.VMName)
+}
+
+func (p *vsphereProvider) PowerOffNode(k8snode *model.K8sNode) error {
+	ctx := p.ctx
+	client, err := p.connect()
+	if err != nil {
+		return err
+	}
+
+	vm, err := p.nodeVM(ctx, client, k8snode)
+	if err != nil {
+		return err
+	}
+
+	return powerOffVM(ctx, vm, k8snode.VMName)
+}
+
+// ShutdownNode shuts the guest down through VMware Tools and waits for the
+// VM to power off, it is powered off hard if Tools are not running or the
+// guest does not stop in time.
+func (p *vsphereProvider) ShutdownNode(k8snode *model.K8sNode) error {
+	ctx := p.ctx
+	client, err := p.connect()
+	if err != nil {
+		return err
+	}
+
+	vm, err := p.nodeVM(ctx, client, k8snode)
+	if err != nil {
+		return err
+	}
+
+	powerstate, err := vm.PowerState(ctx)
+	if err != nil {
+		return err
+	}
+	if powerstate == types.VirtualMachinePowerStatePoweredOff {
+		return nil
+	}
+
+	running, err := vm.IsToolsRunning(ctx)
+	if err != nil {
+		return err
+	}
+	if running {
+		fmt.Printf("Shut down %s ...\n", k8snode.VMName)
+		if err := vm.ShutdownGuest(ctx); err != nil {
+			fmt.Printf("Cannot shut down %s: %s\n", k8snode.VMName, err.Error())
+		} else {
+			waitctx, cancel := context.WithTimeout(ctx, constants.GuestShutdownTimeout)
+			defer cancel()
+			if err := vm.WaitForPowerState(waitctx, types.VirtualMachinePowerStatePoweredOff); err == nil {
+				return nil
+			}
+			fmt.Printf("%s did not shut down in %s\n", k8snode.VMName, constants.GuestShutdownTimeout)
+		}
+	}
+	return powerOffVM(ctx, vm, k8snode.VMName)
+}
+
+func (p *vsphereProvider) GetNodeIP(k8snode *model.K8sNode) (string, error) {
+	ctx := p.ctx
+	client, err := p.connect()
+	if err != nil {
+		return "", err
+	}
+
+	vm, err := p.nodeVM(ctx, client, k8snode)
+	if err != nil {
+		return "", err
+	}
+
+	return waitForNodeIP(ctx, vm, k8snode.NodeNetwork, k8snode.NodeSubnet)
+}
+
+func (p *vsphereProvider) DeleteNode(k8snode *model.K8sNode) error {
+	ctx := p.ctx
+	client, err := p.connect()
+	if err != nil {
+		return err
+	}
+
+	vm, err := p.nodeVM(ctx, client, k8snode)
+	if err != nil {
+		return err
+	}
+
+	if err := powerOffVM(ctx, vm, k8snode.VMName); err != nil {
+		return err
+	}
+	task, err := vm.Destroy(ctx)
+	if err != nil {
+		return err
+	}
+	err = task.Wait(ctx)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s has been destoried\n", k8snode.VMName)
+	return nil
+}
+
+func (p *vsphereProvider) getTemplateVMPath() string {
+	return "/" + path.Join(p.answers.Datacenter, "vm", p.answers.Folder, templateName())
+}
+
+func (p *vsphereProvider) getVMFolder() string {
+	return "/" + path.Join(p.answers.Datacenter, "vm", p.answers.Folder)
+}
+
+func (p *vsphereProvider) FindMasterNodes() ([]*model.K8sNode, error) {
+	answers := p.answers
+
+	ctx := p.ctx
+	client, err := p.connect()
+	if err != nil {
+		return nil, err
+	}
+
+	finder := find.NewFinder(client.Client, true)
+
+	datacenter, err := p.datacenter(finder)
+	if err != nil {
+		return nil, err
+	}
+
+	if answers.IsVCenter {
+		return findTaggedVMs(ctx, client, datacenter.Reference(), map[string]string{
+			constants.RoleField: constants.MasterRole,
+		})
+	}
+
+	// custom attributes are not available in ESX, fall back to the VM name
+	masterName := "kubev-esx-master"
+	m := view.NewManager(client.Client)
+	v, err := m.CreateContainerView(ctx, datacenter.Reference(), []string{"VirtualMachine"}, true)
+	if err != nil {
+		return nil, err
+	}
+	defer v.Destroy(ctx)
+	filter := property.Filter{}
+	filter["name"] = masterName
+	objs, err := v.Find(ctx, nil, filter)
+	if err != nil {
+		return nil, err
+	}
+	masters := []*model.K8sNode{}
+	for _, obj := range objs {
+		masters = append(masters, &model.K8sNode{
+			VMName:     masterName,
+			MasterNode: true,
+			Mo:         obj.String(),
+		})
+	}
+	return masters, nil
+}
+
+func (p *vsphereProvider) FindMasterNode(clusterID string) (*model.K8sNode, error) {
+	masters, err := p.FindMasterNodes()
+	if err != nil {
+		return nil, err
+	}
+	var master *model.K8sNode
+	for _, m := range masters {
+		if m.ClusterID == clusterID {
+			master = m
+			break
+		}
+	}
+	if master == nil {
+		return nil, nil
+	}
+
+	ctx := p.ctx
+	client, err := p.connect()
+	if err != nil {
+		return nil, err
+	}
+
+	vm, err := moVM(client, master)
+	if err != nil {
+		return nil, err
+	}
+
+	powerstate, err := vm.PowerState(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if powerstate != types.VirtualMachinePowerStatePoweredOn {
+		return master, fmt.Errorf("%s is not powered on, cannot recover from it", master.VMName)
+	}
+
+	// the master is on the network of the answers, static addresses come
+	// from the IP pool, pick the node IP the way CreateNode did
+	subnet := ""
+	if p.answers.IPPool != nil {
+		subnet = p.answers.IPPool.CIDR
+	}
+	master.IP, err = waitForNodeIP(ctx, vm, p.answers.Network, subnet)
+	if err != nil {
+		return nil, err
+	}
+
+	return master, nil
+}
+
+func (p *vsphereProvider) FindClusterNodes(clusterID string) ([]*model.K8sNode, error) {
+	if !p.answers.IsVCenter {
+		return nil, nil
+	}
+
+	ctx := p.ctx
+	client, err := p.connect()
+	if err != nil {
+		return nil, err
+	}
+
+	return findTaggedVMs(ctx, client, client.ServiceContent.RootFolder, map[string]string{
+		constants.ClusterIDField: clusterID,
+	})
+}
+
+// staticIPCustomization configures the first NIC of the clone with the address
+// allocated from pool, guest customization also renames the guest to the VM name.
+func staticIPCustomization(vmConfig *model.K8sNode, pool *model.IPPool) (*types.CustomizationSpec, error) {
+	mask, err := utils.IPPoolSubnetMask(pool)
+	if err != nil {
+		return nil, err
+	}
+	return &types.CustomizationSpec{
+		Identity: &types.CustomizationLinuxPrep{
+			HostName: &types.CustomizationFixedName{Name: vmConfig.VMName},
+			Domain:   "local",
+		},
+		GlobalIPSettings: types.CustomizationGlobalIPSettings{
+			DnsServerList: pool.DNS,
+		},
+		NicSettingMap: []types.CustomizationAdapterMapping{
+			{
+				Adapter: types.CustomizationIPSettings{
+					Ip:            &types.CustomizationFixedIp{IpAddress: vmConfig.IP},
+					SubnetMask:    mask,
+					Gateway:       []string{pool.Gateway},
+					DnsServerList: pool.DNS,
+				},
+			},
+		},
+	}, nil
+}
+
+// waitForGuestIP waits until VMware Tools reports ip on any NIC of vm, the
+// guest may report another address before customization has finished.
+func waitForGuestIP(ctx context.Context, vm *object.VirtualMachine, ip string) error {
+	reported := false
+	nics, err := waitForGuestNICs(ctx, vm, func(nics []types.GuestNicInfo) bool {
+		for _, nic := range nics {
+			for _, addr := range nic.IpAddress {
+				if addr == ip {
+					reported = true
+				}
+			}
+		}
+		return reported
+	})
+	if err != nil {
+		return err
+	}
+	if !reported {
+		return fmt.Errorf("%s did not report %s in %s, NICs seen: %s", guestName(ctx, vm), ip, constants.GuestIPTimeout, describeNICs(nics))
+	}
+	return nil
+}
+
+// nodeVM finds the VM of k8snode by its custom attributes, the managed object
+// reference recorded at creation is used if it is not tagged.
+func (p *vsphereProvider) nodeVM(ctx context.Context, client *govmomi.Client, k8snode *model.K8sNode) (*object.VirtualMachine, error) {
+	if p.answers.IsVCenter && k8snode.ClusterID != "" {
+		nodes, err := findTaggedVMs(ctx, client, client.ServiceContent.RootFolder, map[string]string{
+			constants.ClusterIDField: k8snode.ClusterID,
+			constants.NodeField:      k8snode.VMName,
+		})
+		if err == nil && len(nodes) > 0 {
+			return moVM(client, nodes[0])
+		}
+	}
+	return moVM(client, k8snode)
+}
+
+func moVM(client *govmomi.Client, k8snode *model.K8sNode) (*object.VirtualMachine, error) {
+	mos := strings.Split(k8snode.Mo, ":")
+	if len(mos) != 2 {
+		return nil, fmt.Errorf("incorrect configuration for section %s", k8snode.Mo)
+	}
+
+	moref := types.ManagedObjectReference{
+		Type:  mos[0],
+		Value: mos[1],
+	}
+	return object.NewVirtualMachine(client.Client, moref), nil
+}
+
+func powerOnVM(ctx context.Context, vm *object.VirtualMachine, name string) error {
+	powerstate, err := vm.PowerState(ctx)
+	if err != nil {
+		return err
+	}
+	if powerstate == types.VirtualMachinePowerStatePoweredOn {
+		return nil
+	}
+
+	fmt.Printf("Power on %s ...\n", name)
+	task, err := vm.PowerOn(ctx)
+	if err != nil {
+		return err
+	}
+	_, err = task.WaitForResult(ctx, nil)
+	return err
+}
+
+func powerOffVM(ctx context.Context, vm *object.VirtualMachine, name string) error {
+	powerstate, err := vm.PowerState(ctx)
+	if err != nil {
+		return err
+	}
+	if powerstate == types.VirtualMachinePowerStatePoweredOff {
+		return nil
+	}
+
+	fmt.Printf("Power off %s ...\n", name)
+	task, err := vm.PowerOff(ctx)
+	if err != nil {
+		return err
+	}
+	_, err = task.WaitForResult(ctx, nil)
+	return err
+}
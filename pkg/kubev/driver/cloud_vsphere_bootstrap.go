@@ -0,0 +1,136 @@
+// Copyright © 2019 Jeff Wu <jeff.wu.junfei@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package driver
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/jeffwubj/kubev/pkg/kubev/constants"
+	"github.com/jeffwubj/kubev/pkg/kubev/model"
+	"github.com/jeffwubj/kubev/pkg/kubev/utils"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+const bootstrapMetadata = `instance-id: %s
+local-hostname: %s
+`
+
+// bootstrapUserdata lets root in with the kubev key only. Its password stays
+// locked unless commands go through VMware Tools, which then get the hash of
+// the password kubev generated for the cluster. The userdata is removed from
+// the VM once it was applied.
+const bootstrapUserdata = `#cloud-config
+hostname: %s
+preserve_hostname: false
+disable_root: false
+ssh_pwauth: false
+users:
+  - name: %s
+    lock_passwd: %t
+%s    ssh_authorized_keys:
+      - %s
+`
+
+const bootstrapPasswd = "    passwd: %s\n"
+
+// bootstrapExtraConfig returns the cloud-init metadata and userdata of node
+// as guestinfo, the guest applies them on its first boot.
+func bootstrapExtraConfig(answers *model.Answers, node *model.K8sNode) ([]types.BaseOptionValue, error) {
+	key, err := ioutil.ReadFile(constants.GetVMPublicKeyPath())
+	if err != nil {
+		return nil, err
+	}
+
+	passwd := ""
+	if answers.CommandChannel == constants.ToolsChannel {
+		password, err := toolsPassword()
+		if err != nil {
+			return nil, err
+		}
+		salt, err := utils.NewPassword(16)
+		if err != nil {
+			return nil, err
+		}
+		passwd = fmt.Sprintf(bootstrapPasswd, utils.SHA512Crypt(password, salt))
+	}
+
+	metadata := fmt.Sprintf(bootstrapMetadata, node.VMName, node.VMName)
+	userdata := fmt.Sprintf(bootstrapUserdata,
+		node.VMName,
+		constants.PhotonVMUsername,
+		passwd == "",
+		passwd,
+		strings.TrimSpace(string(key)))
+
+	return []types.BaseOptionValue{
+		&types.OptionValue{Key: "guestinfo.metadata", Value: base64.StdEncoding.EncodeToString([]byte(metadata))},
+		&types.OptionValue{Key: "guestinfo.metadata.encoding", Value: "base64"},
+		&types.OptionValue{Key: "guestinfo.userdata", Value: base64.StdEncoding.EncodeToString([]byte(userdata))},
+		&types.OptionValue{Key: "guestinfo.userdata.encoding", Value: "base64"},
+	}, nil
+}
+
+func (p *vsphereProvider) BootstrapApplied(k8snode *model.K8sNode) (bool, error) {
+	ctx := p.ctx
+	client, err := p.connect()
+	if err != nil {
+		return false, err
+	}
+
+	vm, err := p.nodeVM(ctx, client, k8snode)
+	if err != nil {
+		return false, err
+	}
+
+	var mvm mo.VirtualMachine
+	if err := vm.Properties(ctx, vm.Reference(), []string{"guest"}, &mvm); err != nil {
+		return false, err
+	}
+	if mvm.Guest == nil || mvm.Guest.HostName == "" {
+		return false, nil
+	}
+	hostname := strings.SplitN(mvm.Guest.HostName, ".", 2)[0]
+	return strings.EqualFold(hostname, k8snode.VMName), nil
+}
+
+func (p *vsphereProvider) ClearBootstrap(k8snode *model.K8sNode) error {
+	ctx := p.ctx
+	client, err := p.connect()
+	if err != nil {
+		return err
+	}
+
+	vm, err := p.nodeVM(ctx, client, k8snode)
+	if err != nil {
+		return err
+	}
+
+	// empty values remove the keys, the metadata stays so cloud-init
+	// still finds its instance
+	task, err := vm.Reconfigure(ctx, types.VirtualMachineConfigSpec{
+		ExtraConfig: []types.BaseOptionValue{
+			&types.OptionValue{Key: "guestinfo.userdata", Value: ""},
+			&types.OptionValue{Key: "guestinfo.userdata.encoding", Value: ""},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	return task.Wait(ctx)
+}
@@ -0,0 +1,194 @@
+// Copyright © 2019 Jeff Wu <jeff.wu.junfei@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package driver
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jeffwubj/kubev/pkg/kubev/constants"
+	"github.com/vmware/govmomi"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+func cloneVM(ctx context.Context, vm *object.VirtualMachine, folder *object.Folder, name string, spec types.VirtualMachineCloneSpec) error {
+	task, err := vm.Clone(ctx, folder, name, spec)
+	if err != nil {
+		return err
+	}
+
+	// TODO failed with 'The operation is not supported on the object.' if there is orphaned vm with same name
+	// or in esx (https://github.com/vmware/govmomi/pull/486#issuecomment-204326576)
+	_, err = task.WaitForResult(ctx, nil)
+	return err
+}
+
+// prepareLinkedClone makes sure template can be the parent of linked clones
+// placed on datastore, and returns the snapshot they should be created from.
+// The snapshot is only taken once and shared by all linked clones.
+func prepareLinkedClone(ctx context.Context, template *object.VirtualMachine, datastore *object.Datastore) (*types.ManagedObjectReference, error) {
+	host, err := template.HostSystem(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var h mo.HostSystem
+	if err := host.Properties(ctx, host.Reference(), []string{"capability", "datastore"}, &h); err != nil {
+		return nil, err
+	}
+	if h.Capability == nil || h.Capability.CloneFromSnapshotSupported == nil || !*h.Capability.CloneFromSnapshotSupported {
+		return nil, fmt.Errorf("host %s does not support cloning from snapshot", host.Name())
+	}
+
+	mounted := false
+	for _, ref := range h.Datastore {
+		if ref == datastore.Reference() {
+			mounted = true
+		}
+	}
+	if !mounted {
+		return nil, fmt.Errorf("datastore %s is not accessible from host %s", datastore.Name(), host.Name())
+	}
+
+	snapshot, err := template.FindSnapshot(ctx, constants.LinkedCloneSnapshotName)
+	if err == nil {
+		return snapshot, nil
+	}
+
+	fmt.Printf("Snapshot %s for linked clone ...\n", constants.DefaultVMTemplateName)
+	task, err := template.CreateSnapshot(ctx, constants.LinkedCloneSnapshotName, "Base of kubev linked clones", false, false)
+	if err != nil {
+		return nil, err
+	}
+	info, err := task.WaitForResult(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	ref := info.Result.(types.ManagedObjectReference)
+	return &ref, nil
+}
+
+// copyVM creates VM name from the disk of template without a vCenter: the
+// disk is copied next to the new VM and the VM gets the controllers and NICs
+// of template, so nothing has to be uploaded again.
+func copyVM(ctx context.Context, client *govmomi.Client, datacenter *object.Datacenter, template *object.VirtualMachine, folder *object.Folder, resourcepool *object.ResourcePool, datastore *object.Datastore, name string) (*object.VirtualMachine, error) {
+	var t mo.VirtualMachine
+	if err := template.Properties(ctx, template.Reference(), []string{"config"}, &t); err != nil {
+		return nil, err
+	}
+
+	spec := types.VirtualMachineConfigSpec{
+		Name:     name,
+		GuestId:  t.Config.GuestId,
+		Version:  t.Config.Version,
+		Firmware: t.Config.Firmware,
+		NumCPUs:  t.Config.Hardware.NumCPU,
+		MemoryMB: int64(t.Config.Hardware.MemoryMB),
+		Files: &types.VirtualMachineFileInfo{
+			VmPathName: fmt.Sprintf("[%s]", datastore.Name()),
+		},
+	}
+
+	// devices every VM has are created along with it, only controllers,
+	// NICs and disks are taken over with new keys
+	keys := map[int32]int32{}
+	key := int32(-1)
+	devices := object.VirtualDeviceList(t.Config.Hardware.Device)
+	for _, device := range devices.SelectByType((*types.VirtualSCSIController)(nil)) {
+		c := device.(types.BaseVirtualSCSIController).GetVirtualSCSIController()
+		keys[c.Key] = key
+		c.Key = key
+		c.Device = nil
+		key--
+		spec.DeviceChange = append(spec.DeviceChange, &types.VirtualDeviceConfigSpec{
+			Operation: types.VirtualDeviceConfigSpecOperationAdd,
+			Device:    device,
+		})
+	}
+
+	for _, device := range devices.SelectByType((*types.VirtualEthernetCard)(nil)) {
+		nic := device.(types.BaseVirtualEthernetCard).GetVirtualEthernetCard()
+		nic.Key = key
+		nic.ControllerKey = 0
+		nic.UnitNumber = nil
+		nic.AddressType = string(types.VirtualEthernetCardMacTypeGenerated)
+		nic.MacAddress = ""
+		key--
+		spec.DeviceChange = append(spec.DeviceChange, &types.VirtualDeviceConfigSpec{
+			Operation: types.VirtualDeviceConfigSpecOperationAdd,
+			Device:    device,
+		})
+	}
+
+	fm := object.NewFileManager(client.Client)
+	vdm := object.NewVirtualDiskManager(client.Client)
+	dir := fmt.Sprintf("[%s] %s", datastore.Name(), name)
+	if err := fm.MakeDirectory(ctx, dir, datacenter, true); err != nil {
+		return nil, err
+	}
+
+	for i, device := range devices.SelectByType((*types.VirtualDisk)(nil)) {
+		disk := device.(*types.VirtualDisk)
+		backing, ok := disk.Backing.(*types.VirtualDiskFlatVer2BackingInfo)
+		if !ok {
+			return nil, fmt.Errorf("Cannot copy disk %d of %s", i, template.Name())
+		}
+		controller, ok := keys[disk.ControllerKey]
+		if !ok {
+			return nil, fmt.Errorf("Disk %d of %s is not on an SCSI controller", i, template.Name())
+		}
+
+		src := backing.FileName
+		dst := fmt.Sprintf("%s/%s.vmdk", dir, name)
+		if i > 0 {
+			dst = fmt.Sprintf("%s/%s_%d.vmdk", dir, name, i)
+		}
+		fmt.Printf("Copy %s to %s ...\n", src, dst)
+		task, err := vdm.CopyVirtualDisk(ctx, src, datacenter, dst, datacenter, &types.VirtualDiskSpec{
+			DiskType:    string(types.VirtualDiskTypeThin),
+			AdapterType: string(types.VirtualDiskAdapterTypeLsiLogic),
+		}, false)
+		if err != nil {
+			return nil, err
+		}
+		if err := task.Wait(ctx); err != nil {
+			return nil, err
+		}
+
+		ref := datastore.Reference()
+		backing.FileName = dst
+		backing.Datastore = &ref
+		backing.Parent = nil
+		disk.Key = key
+		disk.ControllerKey = controller
+		key--
+		spec.DeviceChange = append(spec.DeviceChange, &types.VirtualDeviceConfigSpec{
+			Operation: types.VirtualDeviceConfigSpecOperationAdd,
+			Device:    disk,
+		})
+	}
+
+	task, err := folder.CreateVM(ctx, spec, resourcepool, nil)
+	if err != nil {
+		return nil, err
+	}
+	info, err := task.WaitForResult(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	return object.NewVirtualMachine(client.Client, info.Result.(types.ManagedObjectReference)), nil
+}
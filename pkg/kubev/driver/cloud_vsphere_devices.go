@@ -0,0 +1,250 @@
+// Copyright © 2019 Jeff Wu <jeff.wu.junfei@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package driver
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jeffwubj/kubev/pkg/kubev/model"
+	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// poolDeviceChange connects the first NIC of vm to the node network of pool
+// and adds the NICs of the extra networks, grows its root disk to the size
+// of pool and adds the data disks vm does not have yet. Clones start with
+// the template's NIC and disk only. Data disks without a datastore of their
+// own are placed next to vm on datastore with the storage policy profile.
+// NICs and the SCSI controller are replaced if they are not of the types of
+// the hardware profile.
+func (p *vsphereProvider) poolDeviceChange(ctx context.Context, finder *find.Finder, vm *object.VirtualMachine, pool *model.NodePool, datastore *object.Datastore, profile []types.BaseVirtualMachineProfileSpec) ([]types.BaseVirtualDeviceConfigSpec, error) {
+	devices, err := vm.Device(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := []types.BaseVirtualDeviceConfigSpec{}
+
+	nics := devices.SelectByType((*types.VirtualEthernetCard)(nil))
+	if len(nics) == 0 {
+		return nil, fmt.Errorf("Cannot find network adapter of %s", vm.Name())
+	}
+	network, err := p.network(finder, pool.Network)
+	if err != nil {
+		return nil, err
+	}
+	backing, err := network.EthernetCardBackingInfo(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// new devices need distinct negative keys within the spec
+	key := int32(-1)
+
+	hardware := p.answers.HardwareProfile
+	ok, err := isNICType(devices, nics[0], nicType(hardware))
+	if err != nil {
+		return nil, err
+	}
+	if hardware == nil || hardware.NICType == "" || ok {
+		nic := nics[0].(types.BaseVirtualEthernetCard).GetVirtualEthernetCard()
+		nic.Backing = backing
+		changes = append(changes, &types.VirtualDeviceConfigSpec{
+			Operation: types.VirtualDeviceConfigSpecOperationEdit,
+			Device:    nics[0],
+		})
+	} else {
+		// the adapter type cannot be edited, the new NIC gets a new MAC
+		nic, err := devices.CreateEthernetCard(nicType(hardware), backing)
+		if err != nil {
+			return nil, err
+		}
+		nic.GetVirtualDevice().Key = key
+		key--
+		changes = append(changes,
+			&types.VirtualDeviceConfigSpec{
+				Operation: types.VirtualDeviceConfigSpecOperationRemove,
+				Device:    nics[0],
+			},
+			&types.VirtualDeviceConfigSpec{
+				Operation: types.VirtualDeviceConfigSpecOperationAdd,
+				Device:    nic,
+			})
+	}
+
+	if len(nics) <= len(pool.ExtraNetworks) {
+		for _, name := range pool.ExtraNetworks[len(nics)-1:] {
+			network, err := p.network(finder, name)
+			if err != nil {
+				return nil, err
+			}
+			backing, err := network.EthernetCardBackingInfo(ctx)
+			if err != nil {
+				return nil, err
+			}
+			nic, err := devices.CreateEthernetCard(nicType(hardware), backing)
+			if err != nil {
+				return nil, err
+			}
+			nic.GetVirtualDevice().Key = key
+			key--
+			changes = append(changes, &types.VirtualDeviceConfigSpec{
+				Operation: types.VirtualDeviceConfigSpecOperationAdd,
+				Device:    nic,
+			})
+		}
+	}
+
+	swap, controller, err := swapSCSIController(devices, hardware)
+	if err != nil {
+		return nil, err
+	}
+	changes = append(changes, swap...)
+	if len(swap) > 0 {
+		devices = append(devices, controller.(types.BaseVirtualDevice))
+	}
+
+	disks := devices.SelectByType((*types.VirtualDisk)(nil))
+	if len(disks) == 0 {
+		return nil, fmt.Errorf("Cannot find disk of %s", vm.Name())
+	}
+
+	if pool.DiskSize > 0 {
+		disk := disks[0].(*types.VirtualDisk)
+		size := int64(pool.DiskSize) * 1024 * 1024
+		if disk.CapacityInKB > size {
+			return nil, fmt.Errorf("Disk of %s is larger than %dGB, disks cannot shrink", vm.Name(), pool.DiskSize)
+		}
+		if disk.CapacityInKB < size {
+			disk.CapacityInKB = size
+			disk.CapacityInBytes = size * 1024
+			// a disk moved to the new controller is edited already
+			if !isEdited(swap, disk) {
+				changes = append(changes, &types.VirtualDeviceConfigSpec{
+					Operation: types.VirtualDeviceConfigSpecOperationEdit,
+					Device:    disk,
+				})
+			}
+		}
+	}
+
+	if len(disks) > len(pool.DataDisks) {
+		return changes, nil
+	}
+	if controller == nil {
+		controller, err = devices.FindDiskController("scsi")
+		if err != nil {
+			return nil, err
+		}
+	}
+	for _, dataDisk := range pool.DataDisks[len(disks)-1:] {
+		diskDatastore := datastore
+		diskProfile := profile
+		if dataDisk.Datastore != "" {
+			diskDatastore, err = p.datastore(finder, dataDisk.Datastore)
+			if err != nil {
+				return nil, err
+			}
+			diskProfile = nil
+		}
+
+		// a bare datastore path makes vSphere name the file after the VM
+		disk := devices.CreateDisk(controller, diskDatastore.Reference(), "")
+		if dataDisk.Datastore != "" {
+			disk.Backing.(*types.VirtualDiskFlatVer2BackingInfo).FileName = fmt.Sprintf("[%s]", diskDatastore.Name())
+		}
+		disk.Key = key
+		key--
+		disk.CapacityInKB = int64(dataDisk.Size) * 1024 * 1024
+		disk.CapacityInBytes = disk.CapacityInKB * 1024
+		devices = append(devices, disk)
+
+		changes = append(changes, &types.VirtualDeviceConfigSpec{
+			Operation:     types.VirtualDeviceConfigSpecOperationAdd,
+			FileOperation: types.VirtualDeviceConfigSpecFileOperationCreate,
+			Device:        disk,
+			Profile:       diskProfile,
+		})
+	}
+
+	return changes, nil
+}
+
+// checkStaticIPNIC refuses to replace the first NIC of template by one of
+// the adapter type of profile for nodes with static IPs, guest
+// customization binds the IP to the NIC of the template and the new NIC
+// would come up without it.
+func checkStaticIPNIC(ctx context.Context, template *object.VirtualMachine, profile *model.HardwareProfile) error {
+	if profile == nil || profile.NICType == "" {
+		return nil
+	}
+	devices, err := template.Device(ctx)
+	if err != nil {
+		return err
+	}
+	nics := devices.SelectByType((*types.VirtualEthernetCard)(nil))
+	if len(nics) == 0 {
+		return fmt.Errorf("Cannot find network adapter of %s", templateName())
+	}
+	ok, err := isNICType(devices, nics[0], profile.NICType)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("The network adapter of template %s is not %s, nodes with static IPs cannot change it, use DHCP or a template with a %s adapter", templateName(), profile.NICType, profile.NICType)
+	}
+	return nil
+}
+
+// nodeDataDisks returns the data disks of pool with the UUIDs of the disks
+// of vm after its root disk.
+func nodeDataDisks(ctx context.Context, vm *object.VirtualMachine, pool *model.NodePool) ([]*model.DataDisk, error) {
+	devices, err := vm.Device(ctx)
+	if err != nil {
+		return nil, err
+	}
+	disks := devices.SelectByType((*types.VirtualDisk)(nil))
+	if len(disks) <= len(pool.DataDisks) {
+		return nil, fmt.Errorf("%s has %d disks, expected %d", vm.Name(), len(disks), len(pool.DataDisks)+1)
+	}
+
+	dataDisks := []*model.DataDisk{}
+	for i, dataDisk := range pool.DataDisks {
+		uuid := diskUUID(disks[i+1].(*types.VirtualDisk))
+		if uuid == "" {
+			return nil, fmt.Errorf("Cannot find UUID of data disk %d of %s", i+1, vm.Name())
+		}
+		dataDisks = append(dataDisks, &model.DataDisk{
+			Size:       dataDisk.Size,
+			Datastore:  dataDisk.Datastore,
+			MountPoint: dataDisk.MountPoint,
+			UUID:       uuid,
+		})
+	}
+	return dataDisks, nil
+}
+
+func diskUUID(disk *types.VirtualDisk) string {
+	switch backing := disk.Backing.(type) {
+	case *types.VirtualDiskFlatVer2BackingInfo:
+		return backing.Uuid
+	case *types.VirtualDiskSeSparseBackingInfo:
+		return backing.Uuid
+	case *types.VirtualDiskSparseVer2BackingInfo:
+		return backing.Uuid
+	}
+	return ""
+}
@@ -0,0 +1,253 @@
+// Copyright © 2019 Jeff Wu <jeff.wu.junfei@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package driver
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jeffwubj/kubev/pkg/kubev/constants"
+	"github.com/jeffwubj/kubev/pkg/kubev/model"
+	"github.com/vmware/govmomi"
+	"github.com/vmware/govmomi/event"
+	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/property"
+	"github.com/vmware/govmomi/vim25/methods"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+func (p *vsphereProvider) Events(k8snodes *model.K8sNodes, count int, stop <-chan struct{}, f func(*model.Event) error) error {
+	ctx, cancel := context.WithCancel(p.ctx)
+	defer cancel()
+
+	client, err := p.connect()
+	if err != nil {
+		return err
+	}
+
+	vms, err := p.eventVMs(ctx, client, k8snodes)
+	if err != nil {
+		return err
+	}
+	if len(vms) == 0 {
+		return fmt.Errorf("Cannot find any VM of the cluster")
+	}
+
+	follow := stop != nil
+	if follow {
+		go func() {
+			select {
+			case <-stop:
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+	}
+
+	// without follow everything is collected first and reported in order
+	var mu sync.Mutex
+	var backlog []*model.Event
+	emit := func(e *model.Event) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if !follow {
+			backlog = append(backlog, e)
+			return nil
+		}
+		return f(e)
+	}
+
+	errs := make(chan error, 2)
+	go func() {
+		errs <- vmEvents(ctx, client, vms, count, follow, emit)
+	}()
+	go func() {
+		errs <- vmTasks(ctx, client, vms, count, follow, emit)
+	}()
+	var firstErr error
+	for i := 0; i < 2; i++ {
+		if err := <-errs; err != nil && ctx.Err() == nil && firstErr == nil {
+			firstErr = err
+			cancel()
+		}
+	}
+	if firstErr != nil {
+		return firstErr
+	}
+
+	sort.SliceStable(backlog, func(i, j int) bool {
+		return backlog[i].Time.Before(backlog[j].Time)
+	})
+	for _, e := range backlog {
+		if err := f(e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// eventVMs returns the names of the VMs of k8snodes and of the template by
+// reference, VMs which do not exist (yet) are left out.
+func (p *vsphereProvider) eventVMs(ctx context.Context, client *govmomi.Client, k8snodes *model.K8sNodes) (map[types.ManagedObjectReference]string, error) {
+	finder := find.NewFinder(client.Client, true)
+	datacenter, err := p.datacenter(finder)
+	if err != nil {
+		return nil, err
+	}
+	finder.SetDatacenter(datacenter)
+
+	vms := map[types.ManagedObjectReference]string{}
+	for _, node := range k8snodes.AllNodes() {
+		// nodes are saved before their VMs are created
+		vm, err := p.nodeVM(ctx, client, node)
+		if err != nil {
+			vm, err = finder.VirtualMachine(ctx, path.Join(p.getVMFolder(), node.VMName))
+		}
+		if err != nil {
+			continue
+		}
+		vms[vm.Reference()] = node.VMName
+	}
+
+	if vm, err := finder.VirtualMachine(ctx, p.getTemplateVMPath()); err == nil {
+		vms[vm.Reference()] = templateName()
+	}
+	return vms, nil
+}
+
+func vmEvents(ctx context.Context, client *govmomi.Client, vms map[types.ManagedObjectReference]string, count int, follow bool, emit func(*model.Event) error) error {
+	m := event.NewManager(client.Client)
+
+	refs := []types.ManagedObjectReference{}
+	for ref := range vms {
+		refs = append(refs, ref)
+	}
+
+	return m.Events(ctx, refs, int32(count), follow, true, func(ref types.ManagedObjectReference, page []types.BaseEvent) error {
+		event.Sort(page)
+		for _, e := range page {
+			severity, err := m.EventCategory(ctx, e)
+			if err != nil {
+				return err
+			}
+			err = emit(&model.Event{
+				Time:     e.GetEvent().CreatedTime,
+				VM:       vms[ref],
+				Severity: severity,
+				Message:  strings.TrimSpace(e.GetEvent().FullFormattedMessage),
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// vmTasks reports the tasks of vms once they succeeded or failed, a task
+// history collector per VM is polled for them.
+func vmTasks(ctx context.Context, client *govmomi.Client, vms map[types.ManagedObjectReference]string, count int, follow bool, emit func(*model.Event) error) error {
+	if client.ServiceContent.TaskManager == nil {
+		return nil
+	}
+
+	// collector -> VM
+	collectors := map[types.ManagedObjectReference]types.ManagedObjectReference{}
+	defer func() {
+		for c := range collectors {
+			methods.DestroyCollector(context.Background(), client.Client, &types.DestroyCollector{This: c})
+		}
+	}()
+	for ref := range vms {
+		res, err := methods.CreateCollectorForTasks(ctx, client.Client, &types.CreateCollectorForTasks{
+			This: *client.ServiceContent.TaskManager,
+			Filter: types.TaskFilterSpec{
+				Entity: &types.TaskFilterSpecByEntity{
+					Entity:    ref,
+					Recursion: types.TaskFilterSpecRecursionOptionSelf,
+				},
+			},
+		})
+		if err != nil {
+			// the VM may have gone meanwhile
+			continue
+		}
+		collectors[res.Returnval] = ref
+		_, err = methods.SetCollectorPageSize(ctx, client.Client, &types.SetCollectorPageSize{
+			This:     res.Returnval,
+			MaxCount: int32(count),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	pc := property.DefaultCollector(client.Client)
+	seen := map[string]bool{}
+	for {
+		for c, ref := range collectors {
+			var h mo.TaskHistoryCollector
+			if err := pc.RetrieveOne(ctx, c, []string{"latestPage"}, &h); err != nil {
+				return err
+			}
+
+			tasks := h.LatestPage
+			sort.Slice(tasks, func(i, j int) bool {
+				return tasks[i].QueueTime.Before(tasks[j].QueueTime)
+			})
+			for _, info := range tasks {
+				if seen[info.Key] || info.CompleteTime == nil {
+					continue
+				}
+				seen[info.Key] = true
+				if err := emit(taskEvent(vms[ref], info)); err != nil {
+					return err
+				}
+			}
+		}
+
+		if !follow {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(constants.TaskPollInterval):
+		}
+	}
+}
+
+func taskEvent(vm string, info types.TaskInfo) *model.Event {
+	e := &model.Event{
+		Time:     *info.CompleteTime,
+		VM:       vm,
+		Severity: "info",
+		Message:  fmt.Sprintf("Task %s succeeded", info.DescriptionId),
+	}
+	if info.State == types.TaskInfoStateError {
+		e.Severity = "error"
+		e.Message = fmt.Sprintf("Task %s failed", info.DescriptionId)
+		if info.Error != nil {
+			e.Message += ": " + info.Error.LocalizedMessage
+		}
+	}
+	return e
+}
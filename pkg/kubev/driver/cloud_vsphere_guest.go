@@ -0,0 +1,185 @@
+// Copyright © 2019 Jeff Wu <jeff.wu.junfei@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package driver
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/jeffwubj/kubev/pkg/kubev/constants"
+	"github.com/jeffwubj/kubev/pkg/kubev/model"
+	"github.com/vmware/govmomi"
+	"github.com/vmware/govmomi/guest"
+	"github.com/vmware/govmomi/vim25/soap"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// vsphereGuest runs programs and copies files through the guest operations
+// of VMware Tools, vCenter or ESX relays them to the VM.
+type vsphereGuest struct {
+	ctx     context.Context
+	client  *govmomi.Client
+	auth    types.BaseGuestAuthentication
+	process *guest.ProcessManager
+	file    *guest.FileManager
+}
+
+// GuestOperations logs in to the guest OS of node as root with the password
+// kubev generated for the cluster, SSH only takes the kubev key.
+func (p *vsphereProvider) GuestOperations(k8snode *model.K8sNode) (GuestOperations, error) {
+	password, err := toolsPassword()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := p.ctx
+	client, err := p.connect()
+	if err != nil {
+		return nil, err
+	}
+
+	vm, err := p.nodeVM(ctx, client, k8snode)
+	if err != nil {
+		return nil, err
+	}
+
+	manager := guest.NewOperationsManager(client.Client, vm.Reference())
+	process, err := manager.ProcessManager(ctx)
+	if err != nil {
+		return nil, err
+	}
+	file, err := manager.FileManager(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &vsphereGuest{
+		ctx:    ctx,
+		client: client,
+		auth: &types.NamePasswordAuthentication{
+			Username: constants.PhotonVMUsername,
+			Password: password,
+		},
+		process: process,
+		file:    file,
+	}, nil
+}
+
+// toolsPassword reads the root password of the nodes kubev generated when it
+// deployed the cluster with the tools command channel.
+func toolsPassword() (string, error) {
+	path := constants.GetVMPasswordPath()
+	password, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return "", fmt.Errorf("Cannot log in through VMware Tools, %s does not exist, it is generated when a cluster is deployed with the %s command channel", path, constants.ToolsChannel)
+	}
+	if err != nil {
+		return "", err
+	}
+	if len(strings.TrimSpace(string(password))) == 0 {
+		return "", fmt.Errorf("Cannot log in through VMware Tools, %s is empty", path)
+	}
+	return strings.TrimSpace(string(password)), nil
+}
+
+// Run lets the shell redirect the output of cmd to a temporary file in the
+// guest, guest operations do not return the output of programs.
+func (g *vsphereGuest) Run(cmd string) (int, []byte, error) {
+	output, err := g.file.CreateTemporaryFile(g.ctx, g.auth, "kubev-", ".log", "")
+	if err != nil {
+		return 0, nil, err
+	}
+	defer g.file.DeleteFile(g.ctx, g.auth, output)
+
+	pid, err := g.process.StartProgram(g.ctx, g.auth, &types.GuestProgramSpec{
+		ProgramPath: "/bin/sh",
+		Arguments:   fmt.Sprintf("-c %s > %s 2>&1", shellQuote(cmd), output),
+	})
+	if err != nil {
+		return 0, nil, err
+	}
+
+	code, err := g.wait(pid)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	b, err := g.download(output)
+	if err != nil {
+		return 0, nil, err
+	}
+	return code, b, nil
+}
+
+// wait polls process pid until it exits and returns its exit code.
+func (g *vsphereGuest) wait(pid int64) (int, error) {
+	for {
+		procs, err := g.process.ListProcesses(g.ctx, g.auth, []int64{pid})
+		if err != nil {
+			return 0, err
+		}
+		if len(procs) != 1 {
+			return 0, fmt.Errorf("Cannot find process %d in guest", pid)
+		}
+		if procs[0].EndTime != nil {
+			return int(procs[0].ExitCode), nil
+		}
+		time.Sleep(constants.GuestPollInterval)
+	}
+}
+
+func (g *vsphereGuest) download(path string) ([]byte, error) {
+	info, err := g.file.InitiateFileTransferFromGuest(g.ctx, g.auth, path)
+	if err != nil {
+		return nil, err
+	}
+	u, err := g.file.TransferURL(g.ctx, info.Url)
+	if err != nil {
+		return nil, err
+	}
+
+	f, _, err := g.client.Client.Download(g.ctx, u, &soap.DefaultDownload)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ioutil.ReadAll(f)
+}
+
+func (g *vsphereGuest) Upload(r io.Reader, size int64, path string, mode int64) error {
+	attributes := &types.GuestPosixFileAttributes{Permissions: mode}
+	target, err := g.file.InitiateFileTransferToGuest(g.ctx, g.auth, path, attributes, size, true)
+	if err != nil {
+		return err
+	}
+	u, err := g.file.TransferURL(g.ctx, target)
+	if err != nil {
+		return err
+	}
+
+	param := soap.DefaultUpload
+	param.ContentLength = size
+	return g.client.Client.Upload(g.ctx, r, u, &param)
+}
+
+// shellQuote makes s a single argument of the shell.
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
@@ -0,0 +1,207 @@
+// Copyright © 2019 Jeff Wu <jeff.wu.junfei@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package driver
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/jeffwubj/kubev/pkg/kubev/model"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// hardwareVersion returns 15 for vmx-15, 0 if version cannot be parsed.
+func hardwareVersion(version string) int {
+	n, _ := strconv.Atoi(strings.TrimPrefix(version, "vmx-"))
+	return n
+}
+
+// upgradeHardware raises the hardware version of the powered off vm to the
+// one of profile, newer versions are kept.
+func upgradeHardware(ctx context.Context, vm *object.VirtualMachine, name string, profile *model.HardwareProfile) error {
+	if profile == nil || profile.Version == "" {
+		return nil
+	}
+
+	var mvm mo.VirtualMachine
+	if err := vm.Properties(ctx, vm.Reference(), []string{"config.version"}, &mvm); err != nil {
+		return err
+	}
+	if hardwareVersion(mvm.Config.Version) >= hardwareVersion(profile.Version) {
+		return nil
+	}
+
+	fmt.Printf("Upgrade hardware of %s from %s to %s ...\n", name, mvm.Config.Version, profile.Version)
+	task, err := vm.UpgradeVM(ctx, profile.Version)
+	if err != nil {
+		return err
+	}
+	return task.Wait(ctx)
+}
+
+func hardwareExtraConfig(profile *model.HardwareProfile) []types.BaseOptionValue {
+	if profile == nil {
+		return nil
+	}
+	options := []types.BaseOptionValue{}
+	for _, option := range profile.ExtraConfig {
+		options = append(options, &types.OptionValue{Key: option.Key, Value: option.Value})
+	}
+	return options
+}
+
+// nicType returns the adapter type of profile, vmxnet3 if it has none.
+func nicType(profile *model.HardwareProfile) string {
+	if profile == nil || profile.NICType == "" {
+		return "vmxnet3"
+	}
+	return profile.NICType
+}
+
+// isNICType tells if nic is an adapter of type name, Ex: vmxnet3.
+func isNICType(devices object.VirtualDeviceList, nic types.BaseVirtualDevice, name string) (bool, error) {
+	want, err := devices.CreateEthernetCard(name, nil)
+	if err != nil {
+		return false, err
+	}
+	return devices.TypeName(want) == devices.TypeName(nic), nil
+}
+
+// swapSCSIController replaces the first SCSI controller of devices by one of
+// the type of profile and moves its devices over, the new controller takes
+// the bus of the old one. It returns no changes and a nil controller if
+// the profile sets no type, or the current controller if the type is right
+// already.
+func swapSCSIController(devices object.VirtualDeviceList, profile *model.HardwareProfile) ([]types.BaseVirtualDeviceConfigSpec, types.BaseVirtualController, error) {
+	if profile == nil || profile.SCSIType == "" {
+		return nil, nil, nil
+	}
+	current, err := devices.FindDiskController("scsi")
+	if err != nil {
+		return nil, nil, err
+	}
+	if devices.Type(current.(types.BaseVirtualDevice)) == profile.SCSIType {
+		return nil, current, nil
+	}
+
+	device, err := devices.CreateSCSIController(profile.SCSIType)
+	if err != nil {
+		return nil, nil, err
+	}
+	old := current.(types.BaseVirtualSCSIController).GetVirtualSCSIController()
+	controller := device.(types.BaseVirtualSCSIController).GetVirtualSCSIController()
+	controller.BusNumber = old.BusNumber
+
+	changes := []types.BaseVirtualDeviceConfigSpec{
+		&types.VirtualDeviceConfigSpec{
+			Operation: types.VirtualDeviceConfigSpecOperationRemove,
+			Device:    current.(types.BaseVirtualDevice),
+		},
+		&types.VirtualDeviceConfigSpec{
+			Operation: types.VirtualDeviceConfigSpecOperationAdd,
+			Device:    device,
+		},
+	}
+	for _, d := range devices {
+		if d.GetVirtualDevice().ControllerKey != old.Key {
+			continue
+		}
+		d.GetVirtualDevice().ControllerKey = controller.Key
+		changes = append(changes, &types.VirtualDeviceConfigSpec{
+			Operation: types.VirtualDeviceConfigSpecOperationEdit,
+			Device:    d,
+		})
+	}
+	return changes, device.(types.BaseVirtualController), nil
+}
+
+// HardwareMismatches lists how the VM of node differs from the hardware
+// profile, or from the one the vSphere CSI driver needs if there is none.
+func (p *vsphereProvider) HardwareMismatches(node *model.K8sNode) ([]string, error) {
+	profile := p.answers.HardwareProfile
+	if profile == nil {
+		profile = model.NewCSIHardwareProfile()
+	}
+
+	ctx := p.ctx
+	client, err := p.connect()
+	if err != nil {
+		return nil, err
+	}
+	vm, err := p.nodeVM(ctx, client, node)
+	if err != nil {
+		return nil, err
+	}
+
+	var mvm mo.VirtualMachine
+	if err := vm.Properties(ctx, vm.Reference(), []string{"config.version", "config.extraConfig", "config.hardware.device"}, &mvm); err != nil {
+		return nil, err
+	}
+
+	mismatches := []string{}
+	if profile.Version != "" && hardwareVersion(mvm.Config.Version) < hardwareVersion(profile.Version) {
+		mismatches = append(mismatches, fmt.Sprintf("hardware %s is older than %s", mvm.Config.Version, profile.Version))
+	}
+
+	options := map[string]string{}
+	for _, option := range mvm.Config.ExtraConfig {
+		o := option.GetOptionValue()
+		options[o.Key] = fmt.Sprintf("%v", o.Value)
+	}
+	for _, option := range profile.ExtraConfig {
+		value, ok := options[option.Key]
+		if !ok {
+			mismatches = append(mismatches, fmt.Sprintf("%s is not set", option.Key))
+		} else if !strings.EqualFold(value, option.Value) {
+			mismatches = append(mismatches, fmt.Sprintf("%s is %s", option.Key, value))
+		}
+	}
+
+	devices := object.VirtualDeviceList(mvm.Config.Hardware.Device)
+	if profile.NICType != "" {
+		for _, nic := range devices.SelectByType((*types.VirtualEthernetCard)(nil)) {
+			ok, err := isNICType(devices, nic, profile.NICType)
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				mismatches = append(mismatches, fmt.Sprintf("%s is not %s", devices.Name(nic), profile.NICType))
+			}
+		}
+	}
+	if profile.SCSIType != "" {
+		for _, controller := range devices.SelectByType((*types.VirtualSCSIController)(nil)) {
+			if devices.Type(controller) != profile.SCSIType {
+				mismatches = append(mismatches, fmt.Sprintf("%s is %s", devices.Name(controller), devices.Type(controller)))
+			}
+		}
+	}
+	return mismatches, nil
+}
+
+// isEdited tells if changes edit device.
+func isEdited(changes []types.BaseVirtualDeviceConfigSpec, device types.BaseVirtualDevice) bool {
+	for _, change := range changes {
+		spec := change.GetVirtualDeviceConfigSpec()
+		if spec.Operation == types.VirtualDeviceConfigSpecOperationEdit && spec.Device == device {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,164 @@
+// Copyright © 2019 Jeff Wu <jeff.wu.junfei@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package driver
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jeffwubj/kubev/pkg/kubev/constants"
+	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/govc/importx"
+	"github.com/vmware/govmomi/nfc"
+	"github.com/vmware/govmomi/vim25/progress"
+	"github.com/vmware/govmomi/vim25/soap"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// uploadLease uploads every file item of the import lease from archive and
+// completes the lease. A failed item is uploaded again from the start, up to
+// constants.UploadRetries times.
+func uploadLease(ctx context.Context, lease *nfc.Lease, info *nfc.LeaseInfo, archive importx.Archive) error {
+	u := lease.StartUpdater(ctx, info)
+	defer u.Done()
+
+	for _, item := range info.Items {
+		for attempt := 1; ; attempt++ {
+			err := uploadItem(ctx, lease, item, archive)
+			if err == nil {
+				break
+			}
+			if attempt == constants.UploadRetries || ctx.Err() != nil {
+				return fmt.Errorf("Cannot upload %s: %s", item.Path, err.Error())
+			}
+			fmt.Printf("Upload of %s failed, retry %d/%d: %s\n", item.Path, attempt, constants.UploadRetries-1, err.Error())
+		}
+	}
+
+	return lease.Complete(ctx)
+}
+
+func uploadItem(ctx context.Context, lease *nfc.Lease, item nfc.FileItem, archive importx.Archive) error {
+	f, size, err := archive.Open(item.Path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	logger := newProgressLogger(fmt.Sprintf("Upload %s", item.Path))
+	opts := soap.Upload{
+		ContentLength: size,
+		Progress:      logger,
+	}
+	err = lease.Upload(ctx, item, f, opts)
+	logger.Wait(ctx)
+	return err
+}
+
+// abortImport aborts the import lease after cause and removes the VM that
+// was created for it, so the next deploy starts over.
+func abortImport(ctx context.Context, finder *find.Finder, lease *nfc.Lease, targetpath string, cause error) {
+	fault := &types.LocalizedMethodFault{
+		Fault:            &types.SystemError{Reason: cause.Error()},
+		LocalizedMessage: cause.Error(),
+	}
+	if err := lease.Abort(ctx, fault); err != nil {
+		fmt.Printf("Cannot abort the import of %s: %s\n", targetpath, err.Error())
+	}
+
+	// vSphere normally removes the VM of an aborted lease itself
+	vm, err := finder.VirtualMachine(ctx, targetpath)
+	if err != nil {
+		return
+	}
+	task, err := vm.Destroy(ctx)
+	if err == nil {
+		err = task.Wait(ctx)
+	}
+	if err != nil {
+		fmt.Printf("Cannot delete %s, delete it manually: %s\n", targetpath, err.Error())
+		return
+	}
+	fmt.Printf("%s has been deleted\n", targetpath)
+}
+
+// progressLogger is a progress.Sinker that prints the percentage and
+// throughput of a transfer on one line, at most once a second.
+type progressLogger struct {
+	prefix  string
+	done    chan struct{}
+	sinking bool
+}
+
+func newProgressLogger(prefix string) *progressLogger {
+	return &progressLogger{
+		prefix: prefix,
+		done:   make(chan struct{}),
+	}
+}
+
+func (l *progressLogger) Sink() chan<- progress.Report {
+	ch := make(chan progress.Report)
+	l.sinking = true
+	go l.loop(ch)
+	return ch
+}
+
+// Wait returns once the transfer has reported its last progress, right away
+// if it never started reporting, or when ctx is done.
+func (l *progressLogger) Wait(ctx context.Context) {
+	if !l.sinking {
+		return
+	}
+	select {
+	case <-l.done:
+	case <-ctx.Done():
+	}
+}
+
+func (l *progressLogger) loop(ch <-chan progress.Report) {
+	defer close(l.done)
+
+	tick := time.NewTicker(time.Second)
+	defer tick.Stop()
+
+	var last progress.Report
+	for {
+		select {
+		case r, ok := <-ch:
+			if !ok {
+				if last != nil {
+					l.print(last)
+					fmt.Println()
+				}
+				return
+			}
+			last = r
+		case <-tick.C:
+			if last != nil {
+				l.print(last)
+			}
+		}
+	}
+}
+
+func (l *progressLogger) print(r progress.Report) {
+	if r.Error() != nil {
+		fmt.Printf("\r%s failed: %s", l.prefix, r.Error().Error())
+		return
+	}
+	fmt.Printf("\r%s %3.0f%% %s    ", l.prefix, r.Percentage(), r.Detail())
+}
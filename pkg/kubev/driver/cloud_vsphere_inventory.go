@@ -0,0 +1,204 @@
+// Copyright © 2019 Jeff Wu <jeff.wu.junfei@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package driver
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/jeffwubj/kubev/pkg/kubev/model"
+	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// PrepareFolder creates every missing folder on the way to the VM folder.
+// ESX has no VM folders.
+func (p *vsphereProvider) PrepareFolder() ([]string, error) {
+	if !p.answers.IsVCenter || strings.Trim(p.answers.Folder, "/") == "" {
+		return nil, nil
+	}
+
+	ctx := p.ctx
+	_, finder, datacenter, err := p.finder()
+	if err != nil {
+		return nil, err
+	}
+	folders, err := datacenter.Folders(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	created := []string{}
+	parent := folders.VmFolder
+	current := "/" + path.Join(p.answers.Datacenter, "vm")
+	for _, name := range strings.Split(strings.Trim(p.answers.Folder, "/"), "/") {
+		current = path.Join(current, name)
+		folder, err := finder.Folder(ctx, current)
+		if err == nil {
+			parent = folder
+			continue
+		}
+		if _, ok := err.(*find.NotFoundError); !ok {
+			return nil, err
+		}
+
+		fmt.Printf("Create folder %s ...\n", current)
+		folder, err = parent.CreateFolder(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		folder.InventoryPath = current
+		parent = folder
+		created = append(created, current)
+	}
+	return created, nil
+}
+
+// PrepareResourcePool creates resource pool name and its missing parents
+// below the deepest existing one, PoolAllocation is applied to name only.
+// ESX deploys to the default pool.
+func (p *vsphereProvider) PrepareResourcePool(name string) ([]string, error) {
+	if !p.answers.IsVCenter || name == "" {
+		return nil, nil
+	}
+
+	ctx := p.ctx
+	_, finder, _, err := p.finder()
+	if err != nil {
+		return nil, err
+	}
+
+	missing := []string{}
+	current := strings.TrimSuffix(name, "/")
+	var parent *object.ResourcePool
+	for {
+		pool, err := finder.ResourcePool(ctx, current)
+		if err == nil {
+			parent = pool
+			break
+		}
+		if _, ok := err.(*find.NotFoundError); !ok {
+			return nil, err
+		}
+		missing = append([]string{path.Base(current)}, missing...)
+		current = path.Dir(current)
+		if current == "." || current == "/" {
+			return nil, fmt.Errorf("Cannot find a parent of resource pool %s, use a path like <cluster>/Resources/%s", name, path.Base(name))
+		}
+	}
+
+	created := []string{}
+	for i, child := range missing {
+		current = path.Join(current, child)
+		spec := types.DefaultResourceConfigSpec()
+		if i == len(missing)-1 && p.answers.PoolAllocation != nil {
+			allocation := p.answers.PoolAllocation
+			spec.CpuAllocation = resourceAllocation(allocation.CpuReservation, allocation.CpuLimit)
+			spec.MemoryAllocation = resourceAllocation(allocation.MemoryReservation, allocation.MemoryLimit)
+		}
+
+		fmt.Printf("Create resource pool %s ...\n", current)
+		pool, err := parent.Create(ctx, child, spec)
+		if err != nil {
+			return nil, err
+		}
+		pool.InventoryPath = current
+		parent = pool
+		created = append(created, current)
+	}
+	return created, nil
+}
+
+func resourceAllocation(reservation, limit int64) types.ResourceAllocationInfo {
+	if limit <= 0 {
+		limit = -1
+	}
+	return types.ResourceAllocationInfo{
+		Reservation:           types.NewInt64(reservation),
+		ExpandableReservation: types.NewBool(true),
+		Limit:                 types.NewInt64(limit),
+		Shares: &types.SharesInfo{
+			Level: types.SharesLevelNormal,
+		},
+	}
+}
+
+// RemoveCreated removes the folders and resource pools in k8snodes,
+// children first. Anything still holding VMs, e.g. the template in the
+// folder, or other pools is kept.
+func (p *vsphereProvider) RemoveCreated(k8snodes *model.K8sNodes) error {
+	if !p.answers.IsVCenter {
+		return nil
+	}
+
+	ctx := p.ctx
+	_, finder, _, err := p.finder()
+	if err != nil {
+		return err
+	}
+
+	for i := len(k8snodes.CreatedResourcePools) - 1; i >= 0; i-- {
+		name := k8snodes.CreatedResourcePools[i]
+		pool, err := finder.ResourcePool(ctx, name)
+		if err != nil {
+			continue
+		}
+		var rp mo.ResourcePool
+		if err := pool.Properties(ctx, pool.Reference(), []string{"vm", "resourcePool"}, &rp); err != nil {
+			return err
+		}
+		if len(rp.Vm) > 0 || len(rp.ResourcePool) > 0 {
+			fmt.Printf("Keep resource pool %s, it is not empty\n", name)
+			continue
+		}
+		if err := destroyObject(ctx, pool.Common); err != nil {
+			return err
+		}
+		fmt.Printf("Resource pool %s has been removed\n", name)
+	}
+
+	for i := len(k8snodes.CreatedFolders) - 1; i >= 0; i-- {
+		name := k8snodes.CreatedFolders[i]
+		folder, err := finder.Folder(ctx, name)
+		if err != nil {
+			continue
+		}
+		var f mo.Folder
+		if err := folder.Properties(ctx, folder.Reference(), []string{"childEntity"}, &f); err != nil {
+			return err
+		}
+		if len(f.ChildEntity) > 0 {
+			fmt.Printf("Keep folder %s, it is not empty\n", name)
+			continue
+		}
+		if err := destroyObject(ctx, folder.Common); err != nil {
+			return err
+		}
+		fmt.Printf("Folder %s has been removed\n", name)
+	}
+	return nil
+}
+
+func destroyObject(ctx context.Context, obj object.Common) error {
+	task, err := obj.Destroy(ctx)
+	if err != nil {
+		return err
+	}
+	return task.Wait(ctx)
+}
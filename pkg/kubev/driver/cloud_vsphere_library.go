@@ -0,0 +1,472 @@
+// Copyright © 2019 Jeff Wu <jeff.wu.junfei@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package driver
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/jeffwubj/kubev/pkg/kubev/constants"
+	"github.com/jeffwubj/kubev/pkg/kubev/model"
+	"github.com/vmware/govmomi"
+	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/vapi/rest"
+	"github.com/vmware/govmomi/vim25/soap"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// The vendored govmomi has no vapi/library package, libraryClient talks to
+// the Content Library and OVF endpoints of the vSphere REST API with the
+// vapi/rest client instead.
+type libraryClient struct {
+	*rest.Client
+}
+
+type libraryFindSpec struct {
+	Name      string `json:"name,omitempty"`
+	LibraryID string `json:"library_id,omitempty"`
+}
+
+type libraryItemSpec struct {
+	LibraryID string `json:"library_id,omitempty"`
+	Name      string `json:"name,omitempty"`
+	Type      string `json:"type,omitempty"`
+}
+
+type libraryItem struct {
+	ID             string `json:"id"`
+	ContentVersion string `json:"content_version"`
+}
+
+type libraryUpdateSessionSpec struct {
+	LibraryItemID string `json:"library_item_id"`
+}
+
+type libraryUpdateSession struct {
+	State        string `json:"state"`
+	ErrorMessage *struct {
+		DefaultMessage string `json:"default_message"`
+	} `json:"error_message,omitempty"`
+}
+
+type libraryFileSpec struct {
+	Name       string `json:"name"`
+	SourceType string `json:"source_type"`
+	Size       int64  `json:"size"`
+}
+
+type libraryFile struct {
+	UploadEndpoint struct {
+		URI string `json:"uri"`
+	} `json:"upload_endpoint"`
+}
+
+type libraryDeploymentTarget struct {
+	ResourcePoolID string `json:"resource_pool_id"`
+	FolderID       string `json:"folder_id,omitempty"`
+}
+
+type libraryProperty struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+type libraryDeploymentSpec struct {
+	Name               string            `json:"name"`
+	AcceptAllEULA      bool              `json:"accept_all_EULA"`
+	DefaultDatastoreID string            `json:"default_datastore_id,omitempty"`
+	NetworkMappings    []libraryProperty `json:"network_mappings,omitempty"`
+}
+
+type libraryDeploymentResult struct {
+	Succeeded  bool `json:"succeeded"`
+	ResourceID *struct {
+		Type string `json:"type"`
+		ID   string `json:"id"`
+	} `json:"resource_id,omitempty"`
+	Error *struct {
+		Errors []struct {
+			Error struct {
+				Messages []struct {
+					DefaultMessage string `json:"default_message"`
+				} `json:"messages"`
+			} `json:"error"`
+		} `json:"errors"`
+	} `json:"error,omitempty"`
+}
+
+func (r *libraryDeploymentResult) err() error {
+	messages := []string{}
+	if r.Error != nil {
+		for _, e := range r.Error.Errors {
+			for _, m := range e.Error.Messages {
+				messages = append(messages, m.DefaultMessage)
+			}
+		}
+	}
+	return fmt.Errorf("Deploy from content library failed: %s", strings.Join(messages, ", "))
+}
+
+// libraryClient returns the REST session of the provider, it is opened next
+// to the SOAP session of client on first use and closed by Close.
+func (p *vsphereProvider) libraryClient(ctx context.Context, client *govmomi.Client) (*libraryClient, error) {
+	if p.library != nil {
+		return p.library, nil
+	}
+
+	c := &libraryClient{Client: rest.NewClient(client.Client)}
+	if err := configureTLS(c.Client.Client, p.answers); err != nil {
+		return nil, err
+	}
+
+	if err := c.Login(ctx, url.UserPassword(p.answers.Username, p.answers.Password)); err != nil {
+		return nil, err
+	}
+	p.library = c
+	return c, nil
+}
+
+// sessionID returns the ID of the REST session, the upload endpoints of the
+// library take it as a header instead of a cookie.
+func (c *libraryClient) sessionID() string {
+	for _, cookie := range c.Jar.Cookies(c.URL()) {
+		if cookie.Name == "vmware-api-session-id" {
+			return cookie.Value
+		}
+	}
+	return ""
+}
+
+// call sends a request to the REST resource path, optionally of object id
+// and calling action, and decodes the value of the response into result.
+func (c *libraryClient) call(ctx context.Context, result interface{}, method, resource, id, action string, body ...interface{}) error {
+	req, err := c.request(method, resource, id, action, body...)
+	if err != nil {
+		return err
+	}
+	return c.Do(ctx, req, result)
+}
+
+func (c *libraryClient) request(method, resource, id, action string, body ...interface{}) (*http.Request, error) {
+	u := c.URL()
+	u.Path = "/rest/com/vmware" + resource
+	if id != "" {
+		u.Path += "/id:" + id
+	}
+	u.RawQuery = ""
+	if action != "" {
+		u.RawQuery = url.Values{"~action": []string{action}}.Encode()
+	}
+
+	b := []byte{}
+	if len(body) != 0 {
+		var err error
+		b, err = json.Marshal(body[0])
+		if err != nil {
+			return nil, err
+		}
+	}
+	return http.NewRequest(method, u.String(), bytes.NewReader(b))
+}
+
+func (c *libraryClient) findLibrary(ctx context.Context, name string) (string, error) {
+	var ids []string
+	if err := c.call(ctx, &ids, http.MethodPost, "/content/library", "", "find", map[string]interface{}{
+		"spec": libraryFindSpec{Name: name},
+	}); err != nil {
+		return "", err
+	}
+	if len(ids) == 0 {
+		return "", fmt.Errorf("Cannot find content library %s", name)
+	}
+	return ids[0], nil
+}
+
+// findItem returns an empty ID if library has no item called name.
+func (c *libraryClient) findItem(ctx context.Context, library, name string) (string, error) {
+	var ids []string
+	if err := c.call(ctx, &ids, http.MethodPost, "/content/library/item", "", "find", map[string]interface{}{
+		"spec": libraryFindSpec{Name: name, LibraryID: library},
+	}); err != nil {
+		return "", err
+	}
+	if len(ids) == 0 {
+		return "", nil
+	}
+	return ids[0], nil
+}
+
+func (c *libraryClient) getItem(ctx context.Context, id string) (*libraryItem, error) {
+	var item libraryItem
+	if err := c.call(ctx, &item, http.MethodGet, "/content/library/item", id, ""); err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+// publishOVA creates item name in library from the files of the OVA at
+// fpath, the item is removed again if the upload fails.
+func (c *libraryClient) publishOVA(ctx context.Context, library, name, fpath string) (string, error) {
+	var item string
+	if err := c.call(ctx, &item, http.MethodPost, "/content/library/item", "", "", map[string]interface{}{
+		"create_spec": libraryItemSpec{LibraryID: library, Name: name, Type: "ovf"},
+	}); err != nil {
+		return "", err
+	}
+
+	if err := c.uploadOVA(ctx, item, fpath); err != nil {
+		c.call(ctx, nil, http.MethodDelete, "/content/library/item", item, "")
+		return "", err
+	}
+	return item, nil
+}
+
+func (c *libraryClient) uploadOVA(ctx context.Context, item, fpath string) error {
+	var session string
+	if err := c.call(ctx, &session, http.MethodPost, "/content/library/item/update-session", "", "", map[string]interface{}{
+		"create_spec": libraryUpdateSessionSpec{LibraryItemID: item},
+	}); err != nil {
+		return err
+	}
+
+	if err := c.uploadFiles(ctx, session, fpath); err != nil {
+		c.call(ctx, nil, http.MethodPost, "/content/library/item/update-session", session, "cancel")
+		return err
+	}
+
+	if err := c.call(ctx, nil, http.MethodPost, "/content/library/item/update-session", session, "complete"); err != nil {
+		return err
+	}
+
+	// the library validates the files after complete
+	for {
+		var info libraryUpdateSession
+		if err := c.call(ctx, &info, http.MethodGet, "/content/library/item/update-session", session, ""); err != nil {
+			return err
+		}
+		switch info.State {
+		case "DONE":
+			return nil
+		case "ERROR", "CANCELED":
+			if info.ErrorMessage != nil {
+				return fmt.Errorf("Publish to content library failed: %s", info.ErrorMessage.DefaultMessage)
+			}
+			return fmt.Errorf("Publish to content library failed: %s", info.State)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+// uploadFiles pushes every file of the OVA, an OVF library item holds the
+// descriptor, manifest and disks as separate files.
+func (c *libraryClient) uploadFiles(ctx context.Context, session, fpath string) error {
+	f, err := os.Open(fpath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	r := tar.NewReader(f)
+	for {
+		header, err := r.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		name := path.Base(header.Name)
+		var file libraryFile
+		if err := c.call(ctx, &file, http.MethodPost, "/content/library/item/updatesession/file", session, "add", map[string]interface{}{
+			"file_spec": libraryFileSpec{Name: name, SourceType: "PUSH", Size: header.Size},
+		}); err != nil {
+			return err
+		}
+
+		u, err := url.Parse(file.UploadEndpoint.URI)
+		if err != nil {
+			return err
+		}
+		param := soap.DefaultUpload
+		param.ContentLength = header.Size
+		param.Headers = map[string]string{"vmware-api-session-id": c.sessionID()}
+		fmt.Printf("Upload %s to content library ...\n", name)
+		if err := c.Upload(ctx, r, u, &param); err != nil {
+			return err
+		}
+	}
+}
+
+// deploy creates VM name from the OVF item with every OVF network mapped to
+// network.
+func (c *libraryClient) deploy(ctx context.Context, item string, target libraryDeploymentTarget, spec libraryDeploymentSpec, network string) (string, error) {
+	var filter struct {
+		Networks []string `json:"networks"`
+	}
+	if err := c.call(ctx, &filter, http.MethodPost, "/vcenter/ovf/library-item", item, "filter", map[string]interface{}{
+		"target": target,
+	}); err != nil {
+		return "", err
+	}
+	for _, name := range filter.Networks {
+		spec.NetworkMappings = append(spec.NetworkMappings, libraryProperty{Key: name, Value: network})
+	}
+
+	var result libraryDeploymentResult
+	if err := c.call(ctx, &result, http.MethodPost, "/vcenter/ovf/library-item", item, "deploy", map[string]interface{}{
+		"target":          target,
+		"deployment_spec": spec,
+	}); err != nil {
+		return "", err
+	}
+	if !result.Succeeded || result.ResourceID == nil {
+		return "", result.err()
+	}
+	return result.ResourceID.ID, nil
+}
+
+// libraryItemName is the Content Library item holding the node image, the
+// template of this kubev version by default.
+func (p *vsphereProvider) libraryItemName() string {
+	if p.answers.LibraryItem != "" {
+		return p.answers.LibraryItem
+	}
+	return templateName()
+}
+
+// currentLibraryItem returns the Content Library item of the node image, or
+// nil if the library does not have it yet.
+func (p *vsphereProvider) currentLibraryItem(ctx context.Context, client *govmomi.Client) (*libraryItem, error) {
+	c, err := p.libraryClient(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+
+	library, err := c.findLibrary(ctx, p.answers.ContentLibrary)
+	if err != nil {
+		return nil, err
+	}
+	item, err := c.findItem(ctx, library, p.libraryItemName())
+	if err != nil || item == "" {
+		return nil, err
+	}
+	return c.getItem(ctx, item)
+}
+
+// deployLibraryItem creates the VM at targetpath from the Content Library
+// item and returns the version of the item it was deployed from, the local
+// OVA is published to the library first if the item does not exist yet.
+//
+// Nodes are cloned from a template deployed from the item rather than
+// deployed from the item one by one: an OVF deployment per node is slow and
+// linked clones need the snapshot of a local VM. templateVM keeps the
+// template in step with the item by comparing the item ID and content
+// version recorded in its annotation, and deploys it again when they differ.
+func (p *vsphereProvider) deployLibraryItem(ctx context.Context, client *govmomi.Client, finder *find.Finder, targetpath string, pool *model.NodePool) (*object.VirtualMachine, *libraryItem, error) {
+	c, err := p.libraryClient(ctx, client)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	library, err := c.findLibrary(ctx, p.answers.ContentLibrary)
+	if err != nil {
+		return nil, nil, err
+	}
+	item, err := c.findItem(ctx, library, p.libraryItemName())
+	if err != nil {
+		return nil, nil, err
+	}
+	if item == "" {
+		fpath := constants.GetLocalK8sKitFilePath(constants.PhotonOVAName, constants.DefaultPhotonVersion)
+		fmt.Printf("Publish %s to content library %s as %s ...\n", filepath.Base(fpath), p.answers.ContentLibrary, p.libraryItemName())
+		item, err = c.publishOVA(ctx, library, p.libraryItemName(), fpath)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	version, err := c.getItem(ctx, item)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	vm, err := finder.VirtualMachine(ctx, targetpath)
+	if err == nil {
+		return vm, version, nil
+	}
+
+	datastore, err := p.datastore(finder, pool.Datastore)
+	if err != nil {
+		return nil, nil, err
+	}
+	resourcepool, err := p.resourcePool(finder, pool.Resourcepool)
+	if err != nil {
+		return nil, nil, err
+	}
+	folder, err := p.folder(finder, p.getVMFolder())
+	if err != nil {
+		return nil, nil, err
+	}
+	network, err := p.network(finder, pool.Network)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	fmt.Printf("Deploy %s/%s to %s ...\n", p.answers.ContentLibrary, p.libraryItemName(), targetpath)
+	id, err := c.deploy(ctx, item, libraryDeploymentTarget{
+		ResourcePoolID: resourcepool.Reference().Value,
+		FolderID:       folder.Reference().Value,
+	}, libraryDeploymentSpec{
+		Name:               path.Base(targetpath),
+		AcceptAllEULA:      true,
+		DefaultDatastoreID: datastore.Reference().Value,
+	}, network.Reference().Value)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	vm = object.NewVirtualMachine(client.Client, types.ManagedObjectReference{Type: "VirtualMachine", Value: id})
+	task, err := vm.Reconfigure(ctx, types.VirtualMachineConfigSpec{
+		NumCPUs:  int32(pool.Cpu),
+		MemoryMB: int64(pool.Memory),
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := task.Wait(ctx); err != nil {
+		return nil, nil, err
+	}
+	return vm, version, nil
+}
@@ -0,0 +1,152 @@
+// Copyright © 2019 Jeff Wu <jeff.wu.junfei@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package driver
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/jeffwubj/kubev/pkg/kubev/constants"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/property"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// Nodes can have several NICs and the guest reports addresses of docker
+// bridges and IPv6 link-local addresses as well, the node IP is the IPv4
+// address on the node network.
+
+// selectNodeIP returns the first IPv4 address in subnet, on the NIC
+// connected to network if subnet is empty, or on the first NIC if both
+// are empty.
+func selectNodeIP(nics []types.GuestNicInfo, network, subnet string) string {
+	var ipnet *net.IPNet
+	if subnet != "" {
+		_, ipnet, _ = net.ParseCIDR(subnet)
+	}
+
+	var first *types.GuestNicInfo
+	for i := range nics {
+		nic := &nics[i]
+		// interfaces created in the guest have no virtual device
+		if nic.DeviceConfigId < 0 {
+			continue
+		}
+		if ipnet == nil && network != "" && nic.Network != network {
+			continue
+		}
+		if first == nil || nic.DeviceConfigId < first.DeviceConfigId {
+			first = nic
+		}
+		if ipnet == nil {
+			continue
+		}
+		for _, addr := range nic.IpAddress {
+			if ip := net.ParseIP(addr).To4(); ip != nil && ipnet.Contains(ip) {
+				return ip.String()
+			}
+		}
+	}
+	if ipnet != nil || first == nil {
+		return ""
+	}
+
+	for _, addr := range first.IpAddress {
+		ip := net.ParseIP(addr).To4()
+		if ip != nil && !ip.IsLinkLocalUnicast() {
+			return ip.String()
+		}
+	}
+	return ""
+}
+
+// waitForNodeIP waits until the guest reports the node IP of vm, see
+// selectNodeIP.
+func waitForNodeIP(ctx context.Context, vm *object.VirtualMachine, network, subnet string) (string, error) {
+	ip := ""
+	nics, err := waitForGuestNICs(ctx, vm, func(nics []types.GuestNicInfo) bool {
+		ip = selectNodeIP(nics, network, subnet)
+		return ip != ""
+	})
+	if err != nil {
+		return "", err
+	}
+	if ip == "" {
+		want := "on the first NIC"
+		if subnet != "" {
+			want = "in " + subnet
+		} else if network != "" {
+			want = "on network " + network
+		}
+		return "", fmt.Errorf("%s reported no IPv4 address %s in %s, NICs seen: %s", guestName(ctx, vm), want, constants.GuestIPTimeout, describeNICs(nics))
+	}
+	return ip, nil
+}
+
+// waitForGuestNICs waits at most GuestIPTimeout until found accepts the
+// NICs VMware Tools reports for vm, and returns the last NICs seen. It
+// returns no error if found did not accept them in time.
+func waitForGuestNICs(ctx context.Context, vm *object.VirtualMachine, found func([]types.GuestNicInfo) bool) ([]types.GuestNicInfo, error) {
+	waitctx, cancel := context.WithTimeout(ctx, constants.GuestIPTimeout)
+	defer cancel()
+
+	var nics []types.GuestNicInfo
+	pc := property.DefaultCollector(vm.Client())
+	err := property.Wait(waitctx, pc, vm.Reference(), []string{"guest.net"}, func(changes []types.PropertyChange) bool {
+		for _, c := range changes {
+			if c.Op != types.PropertyChangeOpAssign || c.Val == nil {
+				continue
+			}
+			info, ok := c.Val.(types.ArrayOfGuestNicInfo)
+			if !ok {
+				continue
+			}
+			nics = info.GuestNicInfo
+			if found(nics) {
+				return true
+			}
+		}
+		return false
+	})
+	if err != nil && waitctx.Err() == context.DeadlineExceeded && ctx.Err() == nil {
+		return nics, nil
+	}
+	return nics, err
+}
+
+// describeNICs lists the networks and addresses of nics for error messages.
+func describeNICs(nics []types.GuestNicInfo) string {
+	described := []string{}
+	for _, nic := range nics {
+		network := nic.Network
+		if network == "" {
+			network = nic.MacAddress
+		}
+		described = append(described, fmt.Sprintf("%s [%s]", network, strings.Join(nic.IpAddress, " ")))
+	}
+	if len(described) == 0 {
+		return "none"
+	}
+	return strings.Join(described, ", ")
+}
+
+func guestName(ctx context.Context, vm *object.VirtualMachine) string {
+	if name, err := vm.ObjectName(ctx); err == nil {
+		return name
+	}
+	return vm.Reference().Value
+}
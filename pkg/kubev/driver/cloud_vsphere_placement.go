@@ -0,0 +1,274 @@
+// Copyright © 2019 Jeff Wu <jeff.wu.junfei@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package driver
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jeffwubj/kubev/pkg/kubev/constants"
+	"github.com/jeffwubj/kubev/pkg/kubev/model"
+	"github.com/vmware/govmomi"
+	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+func antiAffinityRuleName(clusterID string) string {
+	return fmt.Sprintf("kubev-%s", clusterID)
+}
+
+// legacyAntiAffinityRuleNames are the per role rules older versions kept,
+// the rule of all VMs replaces them.
+func legacyAntiAffinityRuleNames(clusterID string) []string {
+	names := []string{}
+	for _, role := range []string{constants.MasterRole, constants.WorkerRole} {
+		names = append(names, fmt.Sprintf("kubev-%s-%s", clusterID, role))
+	}
+	return names
+}
+
+// vmCluster returns the compute cluster vm runs in, or nil if it runs on a
+// standalone host.
+func vmCluster(ctx context.Context, client *govmomi.Client, vm *object.VirtualMachine) (*object.ClusterComputeResource, error) {
+	var mvm mo.VirtualMachine
+	if err := vm.Properties(ctx, vm.Reference(), []string{"resourcePool"}, &mvm); err != nil {
+		return nil, err
+	}
+	if mvm.ResourcePool == nil {
+		return nil, nil
+	}
+
+	var rp mo.ResourcePool
+	pool := object.NewResourcePool(client.Client, *mvm.ResourcePool)
+	if err := pool.Properties(ctx, pool.Reference(), []string{"owner"}, &rp); err != nil {
+		return nil, err
+	}
+	if rp.Owner.Type != "ClusterComputeResource" {
+		return nil, nil
+	}
+	return object.NewClusterComputeResource(client.Client, rp.Owner), nil
+}
+
+// UpdatePlacement keeps one DRS anti-affinity rule of all VMs of the cluster
+// per compute cluster, so workers run apart from each other and from the
+// master, and gives control plane VMs a higher HA restart priority than
+// workers. The rule is optional, DRS still powers on VMs when there are more
+// of them than hosts, and dropped once there are less than two VMs left in
+// it.
+func (p *vsphereProvider) UpdatePlacement(k8snodes *model.K8sNodes) error {
+	if !p.answers.IsVCenter || k8snodes.MasterNode == nil || k8snodes.MasterNode.ClusterID == "" {
+		return nil
+	}
+	clusterID := k8snodes.MasterNode.ClusterID
+
+	ctx := p.ctx
+	client, err := p.connect()
+	if err != nil {
+		return err
+	}
+
+	clusters, err := p.computeClusters(ctx, client)
+	if err != nil {
+		return err
+	}
+
+	// compute cluster -> role -> VMs
+	vms := map[types.ManagedObjectReference]map[string][]types.ManagedObjectReference{}
+	for _, cluster := range clusters {
+		vms[cluster.Reference()] = map[string][]types.ManagedObjectReference{}
+	}
+	for _, node := range k8snodes.AllNodes() {
+		vm, err := p.nodeVM(ctx, client, node)
+		if err != nil {
+			return err
+		}
+		cluster, err := vmCluster(ctx, client, vm)
+		if err != nil {
+			return err
+		}
+		if cluster == nil {
+			continue
+		}
+		roles, ok := vms[cluster.Reference()]
+		if !ok {
+			continue
+		}
+		role := nodeRole(node)
+		roles[role] = append(roles[role], vm.Reference())
+	}
+
+	priorities := map[string]types.ClusterDasVmSettingsRestartPriority{
+		constants.MasterRole: types.ClusterDasVmSettingsRestartPriorityHigh,
+		constants.WorkerRole: types.ClusterDasVmSettingsRestartPriorityLow,
+	}
+
+	for _, cluster := range clusters {
+		config, err := cluster.Configuration(ctx)
+		if err != nil {
+			return err
+		}
+
+		roles := vms[cluster.Reference()]
+		spec := &types.ClusterConfigSpecEx{}
+		all := append([]types.ManagedObjectReference{}, roles[constants.MasterRole]...)
+		all = append(all, roles[constants.WorkerRole]...)
+		spec.RulesSpec = append(spec.RulesSpec, antiAffinityRuleSpec(config, antiAffinityRuleName(clusterID), all)...)
+		for _, name := range legacyAntiAffinityRuleNames(clusterID) {
+			spec.RulesSpec = append(spec.RulesSpec, antiAffinityRuleSpec(config, name, nil)...)
+		}
+
+		for role, refs := range roles {
+			for _, ref := range refs {
+				operation := types.ArrayUpdateOperationAdd
+				for _, c := range config.DasVmConfig {
+					if c.Key == ref {
+						operation = types.ArrayUpdateOperationEdit
+					}
+				}
+				spec.DasVmConfigSpec = append(spec.DasVmConfigSpec, types.ClusterDasVmConfigSpec{
+					ArrayUpdateSpec: types.ArrayUpdateSpec{Operation: operation},
+					Info: &types.ClusterDasVmConfigInfo{
+						Key: ref,
+						DasSettings: &types.ClusterDasVmSettings{
+							RestartPriority: string(priorities[role]),
+						},
+					},
+				})
+			}
+		}
+
+		if len(spec.RulesSpec) == 0 && len(spec.DasVmConfigSpec) == 0 {
+			continue
+		}
+
+		fmt.Printf("Update DRS and HA rules of %s ...\n", cluster.Name())
+		task, err := cluster.Reconfigure(ctx, spec, true)
+		if err != nil {
+			return err
+		}
+		if err := task.Wait(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RemovePlacement drops the DRS rules of cluster clusterID, HA settings go
+// away with the VMs.
+func (p *vsphereProvider) RemovePlacement(clusterID string) error {
+	if !p.answers.IsVCenter || clusterID == "" {
+		return nil
+	}
+
+	ctx := p.ctx
+	client, err := p.connect()
+	if err != nil {
+		return err
+	}
+
+	clusters, err := p.computeClusters(ctx, client)
+	if err != nil {
+		return err
+	}
+
+	for _, cluster := range clusters {
+		config, err := cluster.Configuration(ctx)
+		if err != nil {
+			return err
+		}
+
+		spec := &types.ClusterConfigSpecEx{}
+		for _, name := range append([]string{antiAffinityRuleName(clusterID)}, legacyAntiAffinityRuleNames(clusterID)...) {
+			spec.RulesSpec = append(spec.RulesSpec, antiAffinityRuleSpec(config, name, nil)...)
+		}
+		if len(spec.RulesSpec) == 0 {
+			continue
+		}
+
+		task, err := cluster.Reconfigure(ctx, spec, true)
+		if err != nil {
+			return err
+		}
+		if err := task.Wait(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// computeClusters returns all compute clusters of the datacenter.
+func (p *vsphereProvider) computeClusters(ctx context.Context, client *govmomi.Client) ([]*object.ClusterComputeResource, error) {
+	finder := find.NewFinder(client.Client, true)
+	datacenter, err := p.datacenter(finder)
+	if err != nil {
+		return nil, err
+	}
+	finder.SetDatacenter(datacenter)
+
+	clusters, err := finder.ClusterComputeResourceList(ctx, "*")
+	if _, ok := err.(*find.NotFoundError); ok {
+		return nil, nil
+	}
+	return clusters, err
+}
+
+// antiAffinityRuleSpec returns the change that makes rule name hold exactly
+// vms, nothing if there is nothing to change.
+func antiAffinityRuleSpec(config *types.ClusterConfigInfoEx, name string, vms []types.ManagedObjectReference) []types.ClusterRuleSpec {
+	var existing *types.ClusterRuleInfo
+	for _, rule := range config.Rule {
+		if info := rule.GetClusterRuleInfo(); info.Name == name {
+			existing = info
+		}
+	}
+
+	if len(vms) < 2 {
+		if existing == nil {
+			return nil
+		}
+		return []types.ClusterRuleSpec{
+			{
+				ArrayUpdateSpec: types.ArrayUpdateSpec{
+					Operation: types.ArrayUpdateOperationRemove,
+					RemoveKey: existing.Key,
+				},
+			},
+		}
+	}
+
+	// a mandatory rule keeps VMs from powering on once they outnumber the
+	// hosts
+	rule := &types.ClusterAntiAffinityRuleSpec{
+		ClusterRuleInfo: types.ClusterRuleInfo{
+			Name:      name,
+			Enabled:   types.NewBool(true),
+			Mandatory: types.NewBool(false),
+		},
+		Vm: vms,
+	}
+	operation := types.ArrayUpdateOperationAdd
+	if existing != nil {
+		rule.Key = existing.Key
+		operation = types.ArrayUpdateOperationEdit
+	}
+	return []types.ClusterRuleSpec{
+		{
+			ArrayUpdateSpec: types.ArrayUpdateSpec{Operation: operation},
+			Info:            rule,
+		},
+	}
+}
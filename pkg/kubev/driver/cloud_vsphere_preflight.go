@@ -0,0 +1,351 @@
+// Copyright © 2019 Jeff Wu <jeff.wu.junfei@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package driver
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/jeffwubj/kubev/pkg/kubev/constants"
+	"github.com/jeffwubj/kubev/pkg/kubev/model"
+	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/vim25/methods"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// The privileges listed in the README, by the object they are needed on.
+var (
+	datastorePrivileges = []string{
+		"Datastore.AllocateSpace",
+		"Datastore.FileManagement",
+	}
+	networkPrivileges = []string{
+		"Network.Assign",
+	}
+	resourcePoolPrivileges = []string{
+		"Resource.AssignVMToPool",
+		"VApp.Import",
+	}
+	folderPrivileges = []string{
+		"Folder.Create",
+		"Folder.Delete",
+		"VirtualMachine.Config.AddNewDisk",
+		"VirtualMachine.Config.AddExistingDisk",
+		"VirtualMachine.Config.AddRemoveDevice",
+		"VirtualMachine.Config.CPUCount",
+		"VirtualMachine.Config.Resource",
+		"VirtualMachine.Config.Memory",
+		"VirtualMachine.Config.EditDevice",
+		"VirtualMachine.Config.RemoveDisk",
+		"VirtualMachine.Config.Rename",
+		"VirtualMachine.Config.Settings",
+		"VirtualMachine.Config.AdvancedConfig",
+		"VirtualMachine.Interact.PowerOff",
+		"VirtualMachine.Interact.PowerOn",
+		"VirtualMachine.Inventory.CreateFromExisting",
+		"VirtualMachine.Inventory.Create",
+		"VirtualMachine.Inventory.Delete",
+		"VirtualMachine.Provisioning.Clone",
+		"VirtualMachine.Provisioning.Customize",
+		"VirtualMachine.Provisioning.ReadCustSpecs",
+		"VApp.Import",
+	}
+	rootPrivileges = []string{
+		"StorageProfile.View",
+	}
+	// guestPrivileges are needed on the folder if commands go through
+	// VMware Tools
+	guestPrivileges = []string{
+		"VirtualMachine.GuestOperations.Modify",
+		"VirtualMachine.GuestOperations.Execute",
+		"VirtualMachine.GuestOperations.Query",
+	}
+)
+
+// preflightEntity is an object privileges are checked on.
+type preflightEntity struct {
+	name       string
+	ref        types.ManagedObjectReference
+	privileges []string
+}
+
+func preflightCheck(name, status, format string, args ...interface{}) *model.PreflightCheck {
+	return &model.PreflightCheck{
+		Name:    name,
+		Status:  status,
+		Message: fmt.Sprintf(format, args...),
+	}
+}
+
+// Preflight checks that the networks and datastores exist, the datastores
+// and resource pools can hold the nodes of all pools, and the user has the
+// privileges kubev needs on them.
+func (p *vsphereProvider) Preflight(controlplane *model.NodePool, pools []*model.NodePool) ([]*model.PreflightCheck, error) {
+	ctx := p.ctx
+	client, finder, datacenter, err := p.finder()
+	if err != nil {
+		return nil, err
+	}
+
+	nodepools := append([]*model.NodePool{controlplane}, pools...)
+	checks := []*model.PreflightCheck{}
+	entities := []*preflightEntity{}
+
+	networks := []string{p.answers.Network}
+	for _, pool := range nodepools {
+		networks = append(networks, pool.Network)
+		networks = append(networks, pool.ExtraNetworks...)
+	}
+	for _, name := range uniqueNames(networks) {
+		network, err := p.network(finder, name)
+		if err != nil {
+			checks = append(checks, preflightCheck("Network "+name, constants.PreflightFail, "%s", err.Error()))
+			continue
+		}
+		checks = append(checks, preflightCheck("Network "+name, constants.PreflightPass, "found"))
+		entities = append(entities, &preflightEntity{"network " + name, network.Reference(), networkPrivileges})
+	}
+
+	// storage policies and Storage DRS pick the datastore of each node
+	// when it is cloned, only check that they exist
+	placed := map[string]bool{}
+	for _, pool := range nodepools {
+		if !p.answers.IsVCenter || (pool.StoragePolicy == "" && pool.DatastoreCluster == "") {
+			continue
+		}
+		placed[pool.Name] = true
+		if pool.StoragePolicy != "" {
+			check := "Storage policy " + pool.StoragePolicy
+			if _, err := p.storagePolicyID(ctx, client, pool.StoragePolicy); err != nil {
+				checks = append(checks, preflightCheck(check, constants.PreflightFail, "%s", err.Error()))
+			} else {
+				checks = append(checks, preflightCheck(check, constants.PreflightPass, "found"))
+			}
+		}
+		if pool.DatastoreCluster != "" {
+			check := "Datastore cluster " + pool.DatastoreCluster
+			if _, err := finder.DatastoreCluster(ctx, pool.DatastoreCluster); err != nil {
+				checks = append(checks, preflightCheck(check, constants.PreflightFail, "%s", err.Error()))
+			} else {
+				checks = append(checks, preflightCheck(check, constants.PreflightPass, "found"))
+			}
+		}
+	}
+
+	// GB each datastore needs, the template unless it exists and every
+	// root and data disk. Linked clones share the disk of the template,
+	// which they cannot grow.
+	space := map[string]int64{}
+	template, err := p.existingTemplate(ctx, client, finder, datacenter)
+	if err != nil {
+		return nil, err
+	}
+	if template == nil {
+		space[p.answers.Datastore] = constants.TemplateDiskSize
+	}
+	for _, pool := range nodepools {
+		size := int64(pool.DiskSize)
+		if size == 0 {
+			size = constants.TemplateDiskSize
+		}
+		if p.answers.IsVCenter && p.answers.LinkedClone && pool.DiskSize == 0 {
+			size = 0
+		}
+		if !placed[pool.Name] {
+			space[pool.Datastore] += int64(pool.Replicas) * size
+		}
+		for _, disk := range pool.DataDisks {
+			datastore := disk.Datastore
+			if datastore == "" && placed[pool.Name] {
+				continue
+			}
+			if datastore == "" {
+				datastore = pool.Datastore
+			}
+			space[datastore] += int64(pool.Replicas) * int64(disk.Size)
+		}
+	}
+	datastores := []string{}
+	for name := range space {
+		datastores = append(datastores, name)
+	}
+	sort.Strings(datastores)
+	for _, name := range datastores {
+		check := "Datastore " + name
+		datastore, err := p.datastore(finder, name)
+		if err != nil {
+			checks = append(checks, preflightCheck(check, constants.PreflightFail, "%s", err.Error()))
+			continue
+		}
+		entities = append(entities, &preflightEntity{"datastore " + name, datastore.Reference(), datastorePrivileges})
+
+		var ds mo.Datastore
+		if err := datastore.Properties(ctx, datastore.Reference(), []string{"summary"}, &ds); err != nil {
+			return nil, err
+		}
+		free := ds.Summary.FreeSpace >> 30
+		capacity := ds.Summary.Capacity >> 30
+		switch {
+		case !ds.Summary.Accessible:
+			checks = append(checks, preflightCheck(check, constants.PreflightFail, "not accessible"))
+		case space[name] > free:
+			checks = append(checks, preflightCheck(check, constants.PreflightFail, "needs %d GB, %d GB free", space[name], free))
+		case free-space[name] < capacity/10:
+			checks = append(checks, preflightCheck(check, constants.PreflightWarn, "needs %d GB, less than 10%% would be left of %d GB", space[name], capacity))
+		default:
+			checks = append(checks, preflightCheck(check, constants.PreflightPass, "needs %d GB, %d GB free", space[name], free))
+		}
+	}
+
+	// vCPUs and MB each resource pool needs
+	cpus := map[string]int64{}
+	memory := map[string]int64{}
+	for _, pool := range nodepools {
+		name := pool.Resourcepool
+		if !p.answers.IsVCenter {
+			name = ""
+		}
+		cpus[name] += int64(pool.Replicas * pool.Cpu)
+		memory[name] += int64(pool.Replicas * pool.Memory)
+	}
+	resourcepools := []string{}
+	for name := range cpus {
+		resourcepools = append(resourcepools, name)
+	}
+	sort.Strings(resourcepools)
+	for _, name := range resourcepools {
+		check := "Resource pool " + name
+		var pool *object.ResourcePool
+		if p.answers.IsVCenter {
+			pool, err = p.resourcePool(finder, name)
+		} else {
+			check = "Resource pool of " + p.answers.Serverurl
+			pool, err = finder.ResourcePoolOrDefault(ctx, "")
+		}
+		if _, ok := err.(*find.NotFoundError); ok {
+			checks = append(checks, preflightCheck(check, constants.PreflightWarn, "not found, deploy will create it"))
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		entities = append(entities, &preflightEntity{strings.ToLower(check), pool.Reference(), resourcePoolPrivileges})
+
+		var rp mo.ResourcePool
+		if err := pool.Properties(ctx, pool.Reference(), []string{"runtime", "owner"}, &rp); err != nil {
+			return nil, err
+		}
+		var owner mo.ComputeResource
+		if err := pool.Properties(ctx, rp.Owner, []string{"summary"}, &owner); err != nil {
+			return nil, err
+		}
+		summary := owner.Summary.GetComputeResourceSummary()
+
+		maxMemory := rp.Runtime.Memory.MaxUsage >> 20
+		switch {
+		case memory[name] > maxMemory:
+			checks = append(checks, preflightCheck(check, constants.PreflightFail, "needs %d MB memory, at most %d MB available", memory[name], maxMemory))
+		case memory[name] > summary.EffectiveMemory:
+			checks = append(checks, preflightCheck(check, constants.PreflightWarn, "needs %d MB memory, hosts have %d MB", memory[name], summary.EffectiveMemory))
+		case cpus[name] > int64(summary.NumCpuThreads):
+			checks = append(checks, preflightCheck(check, constants.PreflightWarn, "needs %d vCPUs, hosts have %d CPU threads", cpus[name], summary.NumCpuThreads))
+		default:
+			checks = append(checks, preflightCheck(check, constants.PreflightPass, "needs %d vCPUs and %d MB memory", cpus[name], memory[name]))
+		}
+	}
+
+	folder, err := p.folder(finder, p.getVMFolder())
+	if err != nil {
+		folders, err := datacenter.Folders(ctx)
+		if err != nil {
+			return nil, err
+		}
+		folder = folders.VmFolder
+	}
+	privileges := folderPrivileges
+	if p.answers.CommandChannel == constants.ToolsChannel {
+		privileges = append(append([]string{}, folderPrivileges...), guestPrivileges...)
+	}
+	entities = append(entities, &preflightEntity{"folder " + p.getVMFolder(), folder.Reference(), privileges})
+	if p.answers.IsVCenter {
+		entities = append(entities, &preflightEntity{"vCenter", client.ServiceContent.RootFolder, rootPrivileges})
+	}
+
+	return append(checks, p.checkPrivileges(entities)...), nil
+}
+
+// checkPrivileges returns a check per entity telling which privileges the
+// user misses on it.
+func (p *vsphereProvider) checkPrivileges(entities []*preflightEntity) []*model.PreflightCheck {
+	ctx := p.ctx
+	client, err := p.connect()
+	if err != nil {
+		return []*model.PreflightCheck{preflightCheck("Privileges", constants.PreflightWarn, "%s", err.Error())}
+	}
+	if client.ServiceContent.AuthorizationManager == nil {
+		return []*model.PreflightCheck{preflightCheck("Privileges", constants.PreflightWarn, "cannot be checked on %s", p.answers.Serverurl)}
+	}
+	user, err := client.SessionManager.UserSession(ctx)
+	if err != nil || user == nil {
+		return []*model.PreflightCheck{preflightCheck("Privileges", constants.PreflightWarn, "cannot find the current session")}
+	}
+
+	checks := []*model.PreflightCheck{}
+	for _, entity := range entities {
+		check := "Privileges on " + entity.name
+		res, err := methods.HasPrivilegeOnEntities(ctx, client.Client, &types.HasPrivilegeOnEntities{
+			This:      *client.ServiceContent.AuthorizationManager,
+			Entity:    []types.ManagedObjectReference{entity.ref},
+			SessionId: user.Key,
+			PrivId:    entity.privileges,
+		})
+		if err != nil {
+			checks = append(checks, preflightCheck(check, constants.PreflightWarn, "cannot be checked: %s", err.Error()))
+			continue
+		}
+
+		missing := []string{}
+		for _, result := range res.Returnval {
+			for _, privilege := range result.PrivAvailability {
+				if !privilege.IsGranted {
+					missing = append(missing, privilege.PrivId)
+				}
+			}
+		}
+		if len(missing) > 0 {
+			checks = append(checks, preflightCheck(check, constants.PreflightFail, "missing %s", strings.Join(missing, ", ")))
+			continue
+		}
+		checks = append(checks, preflightCheck(check, constants.PreflightPass, "granted"))
+	}
+	return checks
+}
+
+func uniqueNames(names []string) []string {
+	seen := map[string]bool{}
+	unique := []string{}
+	for _, name := range names {
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		unique = append(unique, name)
+	}
+	sort.Strings(unique)
+	return unique
+}
@@ -0,0 +1,224 @@
+// Copyright © 2019 Jeff Wu <jeff.wu.junfei@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package driver
+
+import (
+	"crypto/sha1"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/jeffwubj/kubev/pkg/kubev/constants"
+	"github.com/vmware/govmomi"
+	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/session"
+	"github.com/vmware/govmomi/vim25"
+)
+
+// A provider logs in once and shares the session among all its operations.
+// Like govc, the session cookie is kept in ~/.kubev/sessions so the next
+// command can reuse it, set KUBEV_PERSIST_SESSION=false to log out when a
+// command is done instead.
+
+func persistSession() bool {
+	return os.Getenv(constants.PersistSessionEnv) != "false"
+}
+
+func (p *vsphereProvider) sessionFile() string {
+	// a session must not outlive a change of the certificate settings
+	key := fmt.Sprintf("%s@%s:%d#insecure=%t#thumbprint=%s#cabundle=%s", p.answers.Username, p.answers.Serverurl, p.answers.Port,
+		p.answers.Insecure, p.answers.Thumbprint, p.answers.CABundle)
+	return filepath.Join(constants.GetKubeVHomeFolder(), "sessions", fmt.Sprintf("%040x", sha1.Sum([]byte(key))))
+}
+
+// connect returns the client of the provider, it restores the persisted
+// session if it is still valid and logs in otherwise.
+func (p *vsphereProvider) connect() (*govmomi.Client, error) {
+	if p.client != nil {
+		return p.client, nil
+	}
+
+	client, err := p.restoreClient()
+	if err != nil || client == nil {
+		client, err = NewClient(p.ctx, p.answers)
+		if err != nil {
+			return nil, err
+		}
+		if err := p.saveClient(client); err != nil {
+			fmt.Printf("Failed to save vSphere session: %s\n", err.Error())
+		}
+	}
+
+	// long deployments idle while nodes boot, keep the session from timing out
+	client.Client.RoundTripper = session.KeepAlive(client.Client.RoundTripper, 5*time.Minute)
+	p.client = client
+	return client, nil
+}
+
+// restoreClient returns nil if there is no valid persisted session.
+func (p *vsphereProvider) restoreClient() (*govmomi.Client, error) {
+	if !persistSession() {
+		return nil, nil
+	}
+
+	f, err := os.Open(p.sessionFile())
+	if err != nil {
+		return nil, nil
+	}
+	defer f.Close()
+
+	c := new(vim25.Client)
+	if err := json.NewDecoder(f).Decode(c); err != nil {
+		return nil, err
+	}
+	if !c.Valid() {
+		return nil, nil
+	}
+	if err := configureTLS(c.Client, p.answers); err != nil {
+		return nil, err
+	}
+
+	m := session.NewManager(c)
+	u, err := m.UserSession(p.ctx)
+	if err != nil || u == nil {
+		return nil, err
+	}
+	return &govmomi.Client{Client: c, SessionManager: m}, nil
+}
+
+func (p *vsphereProvider) saveClient(client *govmomi.Client) error {
+	if !persistSession() {
+		return nil
+	}
+
+	name := p.sessionFile()
+	if err := os.MkdirAll(filepath.Dir(name), 0700); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(name, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return json.NewEncoder(f).Encode(client.Client)
+}
+
+// Close logs out unless the session is persisted, and cancels whatever
+// the provider is still doing.
+func (p *vsphereProvider) Close() error {
+	defer p.cancel()
+	if p.library != nil {
+		if err := p.library.Logout(p.ctx); err != nil {
+			fmt.Printf("Failed to log out of the vSphere REST API: %s\n", err.Error())
+		}
+		p.library = nil
+	}
+	if p.client == nil {
+		return nil
+	}
+	client := p.client
+	p.client = nil
+	p.cache = map[string]object.Reference{}
+
+	if persistSession() {
+		return nil
+	}
+	return client.Logout(p.ctx)
+}
+
+// finder returns a finder set to the datacenter of the provider.
+func (p *vsphereProvider) finder() (*govmomi.Client, *find.Finder, *object.Datacenter, error) {
+	client, err := p.connect()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	finder := find.NewFinder(client.Client, true)
+	datacenter, err := p.datacenter(finder)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	finder.SetDatacenter(datacenter)
+	return client, finder, datacenter, nil
+}
+
+// The inventory does not change while a command runs, so lookups by path
+// are cached for the lifetime of the provider.
+
+func (p *vsphereProvider) lookup(kind, name string, find func() (object.Reference, error)) (object.Reference, error) {
+	key := kind + ":" + name
+	if obj, ok := p.cache[key]; ok {
+		return obj, nil
+	}
+	obj, err := find()
+	if err != nil {
+		return nil, err
+	}
+	p.cache[key] = obj
+	return obj, nil
+}
+
+func (p *vsphereProvider) datacenter(finder *find.Finder) (*object.Datacenter, error) {
+	obj, err := p.lookup("datacenter", p.answers.Datacenter, func() (object.Reference, error) {
+		return finder.Datacenter(p.ctx, p.answers.Datacenter)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return obj.(*object.Datacenter), nil
+}
+
+func (p *vsphereProvider) datastore(finder *find.Finder, name string) (*object.Datastore, error) {
+	obj, err := p.lookup("datastore", name, func() (object.Reference, error) {
+		return finder.Datastore(p.ctx, name)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return obj.(*object.Datastore), nil
+}
+
+func (p *vsphereProvider) resourcePool(finder *find.Finder, name string) (*object.ResourcePool, error) {
+	obj, err := p.lookup("resourcepool", name, func() (object.Reference, error) {
+		return finder.ResourcePool(p.ctx, name)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return obj.(*object.ResourcePool), nil
+}
+
+func (p *vsphereProvider) folder(finder *find.Finder, name string) (*object.Folder, error) {
+	obj, err := p.lookup("folder", name, func() (object.Reference, error) {
+		return finder.Folder(p.ctx, name)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return obj.(*object.Folder), nil
+}
+
+func (p *vsphereProvider) network(finder *find.Finder, name string) (object.NetworkReference, error) {
+	obj, err := p.lookup("network", name, func() (object.Reference, error) {
+		return finder.Network(p.ctx, name)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return obj.(object.NetworkReference), nil
+}
@@ -0,0 +1,123 @@
+// Copyright © 2019 Jeff Wu <jeff.wu.junfei@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package driver
+
+import (
+	"fmt"
+
+	"github.com/jeffwubj/kubev/pkg/kubev/model"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+func (p *vsphereProvider) CreateSnapshot(k8snode *model.K8sNode, name, description string, memory, quiesce bool) error {
+	ctx := p.ctx
+	client, err := p.connect()
+	if err != nil {
+		return err
+	}
+
+	vm, err := p.nodeVM(ctx, client, k8snode)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Snapshot %s as %s ...\n", k8snode.VMName, name)
+	task, err := vm.CreateSnapshot(ctx, name, description, memory, quiesce)
+	if err != nil {
+		return err
+	}
+	return task.Wait(ctx)
+}
+
+func (p *vsphereProvider) ListSnapshots(k8snode *model.K8sNode) ([]*model.Snapshot, error) {
+	ctx := p.ctx
+	client, err := p.connect()
+	if err != nil {
+		return nil, err
+	}
+
+	vm, err := p.nodeVM(ctx, client, k8snode)
+	if err != nil {
+		return nil, err
+	}
+
+	var mvm mo.VirtualMachine
+	if err := vm.Properties(ctx, vm.Reference(), []string{"snapshot"}, &mvm); err != nil {
+		return nil, err
+	}
+	snapshots := []*model.Snapshot{}
+	if mvm.Snapshot != nil {
+		snapshots = appendSnapshots(snapshots, mvm.Snapshot.RootSnapshotList)
+	}
+	return snapshots, nil
+}
+
+// appendSnapshots flattens the snapshot tree, parents first.
+func appendSnapshots(snapshots []*model.Snapshot, tree []types.VirtualMachineSnapshotTree) []*model.Snapshot {
+	for _, node := range tree {
+		snapshots = append(snapshots, &model.Snapshot{
+			Name:        node.Name,
+			Description: node.Description,
+			CreateTime:  node.CreateTime,
+			Memory:      node.State == types.VirtualMachinePowerStatePoweredOn,
+			Quiesced:    node.Quiesced,
+		})
+		snapshots = appendSnapshots(snapshots, node.ChildSnapshotList)
+	}
+	return snapshots
+}
+
+// RevertSnapshot leaves the VM powered off, or suspended if the snapshot
+// has memory, so the caller decides the order nodes come back in.
+func (p *vsphereProvider) RevertSnapshot(k8snode *model.K8sNode, name string) error {
+	ctx := p.ctx
+	client, err := p.connect()
+	if err != nil {
+		return err
+	}
+
+	vm, err := p.nodeVM(ctx, client, k8snode)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Revert %s to %s ...\n", k8snode.VMName, name)
+	task, err := vm.RevertToSnapshot(ctx, name, true)
+	if err != nil {
+		return err
+	}
+	return task.Wait(ctx)
+}
+
+func (p *vsphereProvider) DeleteSnapshot(k8snode *model.K8sNode, name string) error {
+	ctx := p.ctx
+	client, err := p.connect()
+	if err != nil {
+		return err
+	}
+
+	vm, err := p.nodeVM(ctx, client, k8snode)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Delete snapshot %s of %s ...\n", name, k8snode.VMName)
+	task, err := vm.RemoveSnapshot(ctx, name, false, types.NewBool(true))
+	if err != nil {
+		return err
+	}
+	return task.Wait(ctx)
+}
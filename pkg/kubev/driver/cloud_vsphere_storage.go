@@ -0,0 +1,217 @@
+// Copyright © 2019 Jeff Wu <jeff.wu.junfei@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package driver
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jeffwubj/kubev/pkg/kubev/model"
+	"github.com/vmware/govmomi"
+	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/pbm"
+	pbmtypes "github.com/vmware/govmomi/pbm/types"
+	"github.com/vmware/govmomi/property"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// storagePolicyID returns the ID of the SPBM storage policy called name.
+func (p *vsphereProvider) storagePolicyID(ctx context.Context, client *govmomi.Client, name string) (string, error) {
+	if id, ok := p.policies[name]; ok {
+		return id, nil
+	}
+	c, err := pbm.NewClient(ctx, client.Client)
+	if err != nil {
+		return "", err
+	}
+	id, err := c.ProfileIDByName(ctx, name)
+	if err != nil {
+		return "", fmt.Errorf("Cannot find storage policy %s: %s", name, err.Error())
+	}
+	p.policies[name] = id
+	return id, nil
+}
+
+// storageProfile is the profile spec applying the storage policy of pool to
+// a VM or disk, nil if the pool has none.
+func (p *vsphereProvider) storageProfile(ctx context.Context, client *govmomi.Client, pool *model.NodePool) ([]types.BaseVirtualMachineProfileSpec, error) {
+	if !p.answers.IsVCenter || pool.StoragePolicy == "" {
+		return nil, nil
+	}
+	id, err := p.storagePolicyID(ctx, client, pool.StoragePolicy)
+	if err != nil {
+		return nil, err
+	}
+	return []types.BaseVirtualMachineProfileSpec{
+		&types.VirtualMachineDefinedProfileSpec{ProfileId: id},
+	}, nil
+}
+
+// nodeDatastore picks the datastore for clone name of template: the Storage
+// DRS recommendation if pool uses a datastore cluster, the datastore with
+// the most free space among those compatible with the storage policy of
+// pool, preferring pool.Datastore, or simply pool.Datastore.
+func (p *vsphereProvider) nodeDatastore(ctx context.Context, client *govmomi.Client, finder *find.Finder, template *object.VirtualMachine, folder *object.Folder, resourcepool *object.ResourcePool, pool *model.NodePool, name string) (*object.Datastore, error) {
+	if !p.answers.IsVCenter || (pool.DatastoreCluster == "" && pool.StoragePolicy == "") {
+		return p.datastore(finder, pool.Datastore)
+	}
+
+	profile, err := p.storageProfile(ctx, client, pool)
+	if err != nil {
+		return nil, err
+	}
+
+	if pool.DatastoreCluster != "" {
+		pod, err := finder.DatastoreCluster(ctx, pool.DatastoreCluster)
+		if err != nil {
+			return nil, err
+		}
+		return recommendDatastore(ctx, client, pod, template, folder, resourcepool, profile, name)
+	}
+
+	datastores, err := finder.DatastoreList(ctx, "*")
+	if err != nil {
+		return nil, err
+	}
+	return p.compatibleDatastore(ctx, client, datastores, pool)
+}
+
+// recommendDatastore asks Storage DRS of pod where to place clone name, the
+// clone itself is left to the caller so the recommendation is cancelled.
+func recommendDatastore(ctx context.Context, client *govmomi.Client, pod *object.StoragePod, template *object.VirtualMachine, folder *object.Folder, resourcepool *object.ResourcePool, profile []types.BaseVirtualMachineProfileSpec, name string) (*object.Datastore, error) {
+	podref := pod.Reference()
+	templateref := template.Reference()
+	folderref := folder.Reference()
+	resourcepoolref := resourcepool.Reference()
+
+	m := object.NewStorageResourceManager(client.Client)
+	result, err := m.RecommendDatastores(ctx, types.StoragePlacementSpec{
+		Type:         string(types.StoragePlacementSpecPlacementTypeClone),
+		Vm:           &templateref,
+		CloneName:    name,
+		Folder:       &folderref,
+		ResourcePool: &resourcepoolref,
+		CloneSpec: &types.VirtualMachineCloneSpec{
+			Location: types.VirtualMachineRelocateSpec{
+				Pool:    &resourcepoolref,
+				Profile: profile,
+			},
+		},
+		PodSelectionSpec: types.StorageDrsPodSelectionSpec{
+			StoragePod: &podref,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	keys := []string{}
+	for _, recommendation := range result.Recommendations {
+		keys = append(keys, recommendation.Key)
+	}
+	defer m.CancelStorageDrsRecommendation(ctx, keys)
+
+	for _, recommendation := range result.Recommendations {
+		for _, action := range recommendation.Action {
+			if placement, ok := action.(*types.StoragePlacementAction); ok {
+				datastore := object.NewDatastore(client.Client, placement.Destination)
+				if datastore.InventoryPath, err = datastore.ObjectName(ctx); err != nil {
+					return nil, err
+				}
+				fmt.Printf("Storage DRS places %s on %s\n", name, datastore.InventoryPath)
+				return datastore, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("Storage DRS of %s has no placement for %s", pod.Name(), name)
+}
+
+// compatibleDatastore returns the datastore of datastores that the storage
+// policy of pool can be satisfied on.
+func (p *vsphereProvider) compatibleDatastore(ctx context.Context, client *govmomi.Client, datastores []*object.Datastore, pool *model.NodePool) (*object.Datastore, error) {
+	id, err := p.storagePolicyID(ctx, client, pool.StoragePolicy)
+	if err != nil {
+		return nil, err
+	}
+	c, err := pbm.NewClient(ctx, client.Client)
+	if err != nil {
+		return nil, err
+	}
+
+	hubs := []pbmtypes.PbmPlacementHub{}
+	byRef := map[types.ManagedObjectReference]*object.Datastore{}
+	for _, datastore := range datastores {
+		ref := datastore.Reference()
+		hubs = append(hubs, pbmtypes.PbmPlacementHub{HubType: ref.Type, HubId: ref.Value})
+		byRef[ref] = datastore
+	}
+	result, err := c.CheckRequirements(ctx, hubs, nil, []pbmtypes.BasePbmPlacementRequirement{
+		&pbmtypes.PbmPlacementCapabilityProfileRequirement{
+			ProfileId: pbmtypes.PbmProfileId{UniqueId: id},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	refs := []types.ManagedObjectReference{}
+	for _, hub := range result.CompatibleDatastores() {
+		refs = append(refs, types.ManagedObjectReference{Type: hub.HubType, Value: hub.HubId})
+	}
+	if len(refs) == 0 {
+		return nil, fmt.Errorf("No datastore is compatible with storage policy %s", pool.StoragePolicy)
+	}
+
+	var summaries []mo.Datastore
+	pc := property.DefaultCollector(client.Client)
+	if err := pc.Retrieve(ctx, refs, []string{"summary"}, &summaries); err != nil {
+		return nil, err
+	}
+	var best *mo.Datastore
+	for i, ds := range summaries {
+		if !ds.Summary.Accessible {
+			continue
+		}
+		if ds.Summary.Name == pool.Datastore {
+			return byRef[ds.Reference()], nil
+		}
+		if best == nil || ds.Summary.FreeSpace > best.Summary.FreeSpace {
+			best = &summaries[i]
+		}
+	}
+	if best == nil {
+		return nil, fmt.Errorf("No datastore compatible with storage policy %s is accessible", pool.StoragePolicy)
+	}
+	return byRef[best.Reference()], nil
+}
+
+// diskLocators places every disk of template on datastore with profile.
+func diskLocators(ctx context.Context, template *object.VirtualMachine, datastore types.ManagedObjectReference, profile []types.BaseVirtualMachineProfileSpec) ([]types.VirtualMachineRelocateSpecDiskLocator, error) {
+	devices, err := template.Device(ctx)
+	if err != nil {
+		return nil, err
+	}
+	locators := []types.VirtualMachineRelocateSpecDiskLocator{}
+	for _, disk := range devices.SelectByType((*types.VirtualDisk)(nil)) {
+		locators = append(locators, types.VirtualMachineRelocateSpecDiskLocator{
+			DiskId:    disk.GetVirtualDevice().Key,
+			Datastore: datastore,
+			Profile:   profile,
+		})
+	}
+	return locators, nil
+}
@@ -0,0 +1,217 @@
+// Copyright © 2019 Jeff Wu <jeff.wu.junfei@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package driver
+
+import (
+	"archive/tar"
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/jeffwubj/kubev/pkg/kubev/constants"
+	"github.com/jeffwubj/kubev/pkg/kubev/utils"
+	"github.com/vmware/govmomi/govc/importx"
+)
+
+// ovaArchive opens the OVA in the local cache, or at its download URL if it
+// is not cached and answers.StreamOVA is set. Either way every file read
+// from it is checked against the manifest of the OVA.
+func (p *vsphereProvider) ovaArchive(ctx context.Context, targetpath string) (importx.Archive, error) {
+	fpath := constants.GetLocalK8sKitFilePath(constants.PhotonOVAName, constants.DefaultPhotonVersion)
+
+	var archive importx.Archive
+	if p.answers.StreamOVA && !utils.FileExists(fpath) {
+		fmt.Printf("Stream %s to %s ...\n", constants.PhotonOVAName, targetpath)
+		archive = &streamArchive{
+			ctx: ctx,
+			url: constants.GetK8sKitReleaseURL(constants.PhotonOVAName, constants.DefaultPhotonVersion),
+		}
+	} else {
+		fmt.Printf("Deploy %s to %s ...\n", filepath.Base(fpath), targetpath)
+		tape := &importx.TapeArchive{}
+		tape.SetPath(fpath)
+		archive = tape
+	}
+	return newManifestArchive(archive)
+}
+
+// streamArchive reads the files of an OVA straight from its URL. Every Open
+// downloads the OVA up to the end of the file, which is cheap for the
+// descriptor and manifest at its start, so disks are transferred once and
+// nothing is staged locally.
+type streamArchive struct {
+	ctx context.Context
+	url string
+}
+
+type archiveEntry struct {
+	io.Reader
+	io.Closer
+}
+
+func (s *streamArchive) Open(name string) (io.ReadCloser, int64, error) {
+	req, err := http.NewRequest("GET", s.url, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	res, err := http.DefaultClient.Do(req.WithContext(s.ctx))
+	if err != nil {
+		return nil, 0, err
+	}
+	if res.StatusCode != http.StatusOK {
+		res.Body.Close()
+		return nil, 0, fmt.Errorf("Cannot download %s: %s", s.url, res.Status)
+	}
+
+	r := tar.NewReader(res.Body)
+	for {
+		h, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			res.Body.Close()
+			return nil, 0, err
+		}
+
+		matched, err := path.Match(name, path.Base(h.Name))
+		if err != nil {
+			res.Body.Close()
+			return nil, 0, err
+		}
+		if matched {
+			return &archiveEntry{r, res.Body}, h.Size, nil
+		}
+
+		// the descriptor, manifest and certificate precede all other
+		// files, do not download the disks looking for them
+		if isDescriptorFile(name) && !isDescriptorFile(h.Name) {
+			break
+		}
+	}
+
+	res.Body.Close()
+	return nil, 0, os.ErrNotExist
+}
+
+func isDescriptorFile(name string) bool {
+	switch path.Ext(name) {
+	case ".ovf", ".mf", ".cert":
+		return true
+	}
+	return false
+}
+
+var manifestLine = regexp.MustCompile(`^(SHA1|SHA256|SHA512)\((.+)\)\s*=\s*([0-9a-fA-F]+)$`)
+
+type manifestSum struct {
+	algorithm string
+	sum       string
+}
+
+// manifestArchive fails reading a file of the OVA if its checksum does not
+// match the manifest.
+type manifestArchive struct {
+	importx.Archive
+	sums map[string]manifestSum
+}
+
+func newManifestArchive(archive importx.Archive) (*manifestArchive, error) {
+	a := &manifestArchive{
+		Archive: archive,
+		sums:    map[string]manifestSum{},
+	}
+
+	r, _, err := archive.Open("*.mf")
+	if os.IsNotExist(err) {
+		fmt.Println("The OVA has no manifest, its files cannot be verified")
+		return a, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		m := manifestLine.FindStringSubmatch(strings.TrimSpace(scanner.Text()))
+		if m == nil {
+			continue
+		}
+		a.sums[m[2]] = manifestSum{algorithm: m[1], sum: strings.ToLower(m[3])}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+func (a *manifestArchive) Open(name string) (io.ReadCloser, int64, error) {
+	r, size, err := a.Archive.Open(name)
+	if err != nil || len(a.sums) == 0 {
+		return r, size, err
+	}
+
+	for file, sum := range a.sums {
+		if matched, _ := path.Match(name, file); !matched {
+			continue
+		}
+		var h hash.Hash
+		switch sum.algorithm {
+		case "SHA1":
+			h = sha1.New()
+		case "SHA256":
+			h = sha256.New()
+		default:
+			h = sha512.New()
+		}
+		return &verifyingReader{ReadCloser: r, name: file, hash: h, sum: sum.sum}, size, nil
+	}
+
+	r.Close()
+	return nil, 0, fmt.Errorf("%s is not in the manifest of the OVA", name)
+}
+
+// verifyingReader returns an error instead of io.EOF if the data read does
+// not match sum, so an upload of it fails.
+type verifyingReader struct {
+	io.ReadCloser
+	name string
+	hash hash.Hash
+	sum  string
+}
+
+func (v *verifyingReader) Read(b []byte) (int, error) {
+	n, err := v.ReadCloser.Read(b)
+	v.hash.Write(b[:n])
+	if err == io.EOF {
+		if sum := hex.EncodeToString(v.hash.Sum(nil)); sum != v.sum {
+			return n, fmt.Errorf("Checksum of %s is %s, the manifest expects %s", v.name, sum, v.sum)
+		}
+	}
+	return n, err
+}
@@ -0,0 +1,133 @@
+// Copyright © 2019 Jeff Wu <jeff.wu.junfei@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package driver
+
+import (
+	"context"
+
+	"github.com/jeffwubj/kubev/pkg/kubev/constants"
+	"github.com/jeffwubj/kubev/pkg/kubev/model"
+	"github.com/vmware/govmomi"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/view"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// Cluster VMs carry custom attributes so they can be found again even if
+// someone renames them, custom attributes are only available in vCenter.
+
+func nodeRole(node *model.K8sNode) string {
+	if node.MasterNode {
+		return constants.MasterRole
+	}
+	return constants.WorkerRole
+}
+
+func customFieldKey(ctx context.Context, m *object.CustomFieldsManager, name string) (int32, error) {
+	key, err := m.FindKey(ctx, name)
+	if err == object.ErrKeyNameNotFound {
+		def, err := m.Add(ctx, name, "VirtualMachine", nil, nil)
+		if err != nil {
+			return -1, err
+		}
+		return def.Key, nil
+	}
+	return key, err
+}
+
+// tagVM records cluster ID, role, node name and kubev version of node on vm.
+func tagVM(ctx context.Context, client *govmomi.Client, vm *object.VirtualMachine, node *model.K8sNode) error {
+	m, err := object.GetCustomFieldsManager(client.Client)
+	if err != nil {
+		return err
+	}
+
+	values := map[string]string{
+		constants.ClusterIDField: node.ClusterID,
+		constants.RoleField:      nodeRole(node),
+		constants.NodeField:      node.VMName,
+		constants.VersionField:   constants.KubeVVersion,
+	}
+	for name, value := range values {
+		key, err := customFieldKey(ctx, m, name)
+		if err != nil {
+			return err
+		}
+		if err := m.Set(ctx, vm.Reference(), key, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// findTaggedVMs returns the nodes of all VMs under container whose custom
+// attributes match every entry of filter.
+func findTaggedVMs(ctx context.Context, client *govmomi.Client, container types.ManagedObjectReference, filter map[string]string) ([]*model.K8sNode, error) {
+	m, err := object.GetCustomFieldsManager(client.Client)
+	if err != nil {
+		return nil, err
+	}
+	fields, err := m.Field(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	v, err := view.NewManager(client.Client).CreateContainerView(ctx, container, []string{"VirtualMachine"}, true)
+	if err != nil {
+		return nil, err
+	}
+	defer v.Destroy(ctx)
+
+	var vms []mo.VirtualMachine
+	if err := v.Retrieve(ctx, []string{"VirtualMachine"}, []string{"name", "customValue"}, &vms); err != nil {
+		return nil, err
+	}
+
+	nodes := []*model.K8sNode{}
+	for _, vm := range vms {
+		values := map[string]string{}
+		for _, cv := range vm.CustomValue {
+			value, ok := cv.(*types.CustomFieldStringValue)
+			if !ok {
+				continue
+			}
+			if def := fields.ByKey(value.Key); def != nil {
+				values[def.Name] = value.Value
+			}
+		}
+
+		if values[constants.ClusterIDField] == "" {
+			continue
+		}
+		matched := true
+		for name, value := range filter {
+			if values[name] != value {
+				matched = false
+			}
+		}
+		if !matched {
+			continue
+		}
+
+		nodes = append(nodes, &model.K8sNode{
+			VMName:     values[constants.NodeField],
+			ClusterID:  values[constants.ClusterIDField],
+			MasterNode: values[constants.RoleField] == constants.MasterRole,
+			Mo:         vm.Reference().String(),
+		})
+	}
+	return nodes, nil
+}
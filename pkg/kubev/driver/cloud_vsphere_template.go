@@ -0,0 +1,413 @@
+// Copyright © 2019 Jeff Wu <jeff.wu.junfei@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package driver
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jeffwubj/kubev/pkg/kubev/constants"
+	"github.com/jeffwubj/kubev/pkg/kubev/model"
+	"github.com/vmware/govmomi"
+	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/property"
+	"github.com/vmware/govmomi/view"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// templateName is the name of the template for the current OS and kubev
+// version, every cluster in a datacenter deployed by this version shares it.
+func templateName() string {
+	return fmt.Sprintf("%s-photon-%s-%s", constants.DefaultVMTemplateName, constants.DefaultPhotonVersion, constants.KubeVVersion)
+}
+
+// templatePool places the template with the cluster wide settings, node
+// pools only apply to the clones.
+func (p *vsphereProvider) templatePool() *model.NodePool {
+	return &model.NodePool{
+		Cpu:          p.answers.Cpu,
+		Memory:       p.answers.Memory,
+		Datastore:    p.answers.Datastore,
+		Network:      p.answers.Network,
+		Resourcepool: p.answers.Resourcepool,
+	}
+}
+
+func templateAnnotation(item *libraryItem) string {
+	annotation := fmt.Sprintf("Created by kubev\nos: photon %s\nkubev: %s\n", constants.DefaultPhotonVersion, constants.KubeVVersion)
+	if item != nil {
+		annotation += fmt.Sprintf("library item: %s\nlibrary version: %s\n", item.ID, item.ContentVersion)
+	}
+	return annotation
+}
+
+func parseTemplateAnnotation(name, annotation string) *model.Template {
+	template := &model.Template{Name: name}
+	for _, line := range strings.Split(annotation, "\n") {
+		kv := strings.SplitN(line, ":", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		switch strings.TrimSpace(kv[0]) {
+		case "os":
+			template.OSVersion = strings.TrimSpace(kv[1])
+		case "kubev":
+			template.KubeVVersion = strings.TrimSpace(kv[1])
+		case "library item":
+			template.LibraryItem = strings.TrimSpace(kv[1])
+		case "library version":
+			template.LibraryVersion = strings.TrimSpace(kv[1])
+		}
+	}
+	return template
+}
+
+// templateVM returns the template for the current versions, the OVA is
+// imported, or deployed from the Content Library item if a library is set,
+// and marked as template if it cannot be found in datacenter. A template
+// deployed from another version of the library item, going by the item ID
+// and content version in its annotation, is renamed and deployed again. A standalone ESX has no templates, the imported VM is kept powered
+// off and nodes get copies of its disk.
+func (p *vsphereProvider) templateVM(ctx context.Context, client *govmomi.Client, finder *find.Finder, datacenter *object.Datacenter) (*object.VirtualMachine, error) {
+	if p.answers.IsVCenter {
+		vm, err := findTemplate(ctx, client, datacenter, templateName())
+		if err != nil {
+			return nil, err
+		}
+		if vm != nil {
+			current, err := p.isCurrentTemplate(ctx, client, vm)
+			if err != nil {
+				return nil, err
+			}
+			if current {
+				fmt.Printf("Use template %s\n", templateName())
+				return vm, nil
+			}
+			if err := renameOutdatedTemplate(ctx, vm); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	targetpath := p.getTemplateVMPath()
+	if err := deleteVMIfPoweredOn(ctx, finder, targetpath); err != nil {
+		return nil, err
+	}
+
+	var vm *object.VirtualMachine
+	var item *libraryItem
+	var err error
+	if p.answers.IsVCenter && p.answers.ContentLibrary != "" {
+		vm, item, err = p.deployLibraryItem(ctx, client, finder, targetpath, p.templatePool())
+	} else {
+		vm, err = p.deployOVA(ctx, client, finder, targetpath, p.templatePool())
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if !p.answers.IsVCenter {
+		return vm, nil
+	}
+
+	fmt.Printf("Mark %s as template ...\n", templateName())
+	task, err := vm.Reconfigure(ctx, types.VirtualMachineConfigSpec{
+		Annotation: templateAnnotation(item),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := task.Wait(ctx); err != nil {
+		return nil, err
+	}
+
+	// templates cannot be snapshotted, take the base of linked clones now
+	if _, err := vm.FindSnapshot(ctx, constants.LinkedCloneSnapshotName); err != nil {
+		task, err := vm.CreateSnapshot(ctx, constants.LinkedCloneSnapshotName, "Base of kubev linked clones", false, false)
+		if err != nil {
+			return nil, err
+		}
+		if err := task.Wait(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := vm.MarkAsTemplate(ctx); err != nil {
+		return nil, err
+	}
+	return vm, nil
+}
+
+// isCurrentTemplate tells whether template was deployed from the current
+// version of the Content Library item, any template is current without a
+// library or before the item is published.
+func (p *vsphereProvider) isCurrentTemplate(ctx context.Context, client *govmomi.Client, template *object.VirtualMachine) (bool, error) {
+	if p.answers.ContentLibrary == "" {
+		return true, nil
+	}
+	item, err := p.currentLibraryItem(ctx, client)
+	if err != nil || item == nil {
+		return true, err
+	}
+
+	var mvm mo.VirtualMachine
+	if err := template.Properties(ctx, template.Reference(), []string{"config.annotation"}, &mvm); err != nil {
+		return false, err
+	}
+	deployed := parseTemplateAnnotation(templateName(), mvm.Config.Annotation)
+	return deployed.LibraryItem == item.ID && deployed.LibraryVersion == item.ContentVersion, nil
+}
+
+// renameOutdatedTemplate moves template out of the way of the new one, the
+// linked clones of existing nodes still need its snapshot.
+func renameOutdatedTemplate(ctx context.Context, template *object.VirtualMachine) error {
+	name := fmt.Sprintf("%s-%s", templateName(), time.Now().Format("20060102150405"))
+	fmt.Printf("Template %s is not of the current content library item, rename it to %s ...\n", templateName(), name)
+	task, err := template.Rename(ctx, name)
+	if err != nil {
+		return err
+	}
+	return task.Wait(ctx)
+}
+
+// deleteVMIfPoweredOn removes a half imported template, it may have been
+// modified since someone powered it on.
+func deleteVMIfPoweredOn(ctx context.Context, finder *find.Finder, vmpath string) error {
+	vm, err := finder.VirtualMachine(ctx, vmpath)
+	if err != nil {
+		return nil
+	}
+	powerstate, err := vm.PowerState(ctx)
+	if err != nil || powerstate == types.VirtualMachinePowerStatePoweredOff {
+		return nil
+	}
+
+	if err := powerOffVM(ctx, vm, vmpath); err != nil {
+		return err
+	}
+	task, err := vm.Destroy(ctx)
+	if err != nil {
+		return err
+	}
+	if err := task.Wait(ctx); err != nil {
+		return err
+	}
+	fmt.Printf("%s has been deleted and will redeploy it\n", vmpath)
+	return nil
+}
+
+// existingTemplate returns the template of the current versions, or nil if
+// deploy has to import it.
+func (p *vsphereProvider) existingTemplate(ctx context.Context, client *govmomi.Client, finder *find.Finder, datacenter *object.Datacenter) (*object.VirtualMachine, error) {
+	if p.answers.IsVCenter {
+		return findTemplate(ctx, client, datacenter, templateName())
+	}
+	vm, err := finder.VirtualMachine(ctx, p.getTemplateVMPath())
+	if _, ok := err.(*find.NotFoundError); ok {
+		return nil, nil
+	}
+	return vm, err
+}
+
+// findTemplate returns nil if there is no template called name in datacenter.
+func findTemplate(ctx context.Context, client *govmomi.Client, datacenter *object.Datacenter, name string) (*object.VirtualMachine, error) {
+	m := view.NewManager(client.Client)
+	v, err := m.CreateContainerView(ctx, datacenter.Reference(), []string{"VirtualMachine"}, true)
+	if err != nil {
+		return nil, err
+	}
+	defer v.Destroy(ctx)
+
+	filter := property.Filter{}
+	filter["name"] = name
+	filter["config.template"] = true
+	objs, err := v.Find(ctx, nil, filter)
+	if err != nil {
+		return nil, err
+	}
+	if len(objs) == 0 {
+		return nil, nil
+	}
+	return object.NewVirtualMachine(client.Client, objs[0]), nil
+}
+
+func (p *vsphereProvider) ListTemplates() ([]*model.Template, error) {
+	if !p.answers.IsVCenter {
+		return nil, fmt.Errorf("Templates are only used in vCenter")
+	}
+
+	ctx := p.ctx
+	client, err := p.connect()
+	if err != nil {
+		return nil, err
+	}
+
+	finder := find.NewFinder(client.Client, true)
+	datacenter, err := p.datacenter(finder)
+	if err != nil {
+		return nil, err
+	}
+
+	m := view.NewManager(client.Client)
+	v, err := m.CreateContainerView(ctx, datacenter.Reference(), []string{"VirtualMachine"}, true)
+	if err != nil {
+		return nil, err
+	}
+	defer v.Destroy(ctx)
+
+	var vms []mo.VirtualMachine
+	if err := v.Retrieve(ctx, []string{"VirtualMachine"}, []string{"name", "config.template", "config.annotation"}, &vms); err != nil {
+		return nil, err
+	}
+
+	templates := []*model.Template{}
+	for _, vm := range vms {
+		if vm.Config == nil || !vm.Config.Template || !strings.HasPrefix(vm.Name, constants.DefaultVMTemplateName) {
+			continue
+		}
+		templates = append(templates, parseTemplateAnnotation(vm.Name, vm.Config.Annotation))
+	}
+	return templates, nil
+}
+
+// DeleteTemplate refuses to delete a template that still backs linked clones
+// unless force is set, the clones cannot boot without its disks.
+func (p *vsphereProvider) DeleteTemplate(name string, force bool) error {
+	if !p.answers.IsVCenter {
+		return fmt.Errorf("Templates are only used in vCenter")
+	}
+
+	ctx := p.ctx
+	client, err := p.connect()
+	if err != nil {
+		return err
+	}
+
+	finder := find.NewFinder(client.Client, true)
+	datacenter, err := p.datacenter(finder)
+	if err != nil {
+		return err
+	}
+
+	vm, err := findTemplate(ctx, client, datacenter, name)
+	if err != nil {
+		return err
+	}
+	if vm == nil {
+		return fmt.Errorf("Cannot find template %s", name)
+	}
+
+	users, err := templateUsers(ctx, client, datacenter, vm)
+	if err != nil {
+		return err
+	}
+	if len(users) > 0 {
+		if !force {
+			return fmt.Errorf("Template %s is still used by the linked clones %s, use --force to delete it anyway", name, strings.Join(users, ", "))
+		}
+		fmt.Printf("Delete %s, the linked clones %s will stop working\n", name, strings.Join(users, ", "))
+	}
+
+	task, err := vm.Destroy(ctx)
+	if err != nil {
+		return err
+	}
+	if err := task.Wait(ctx); err != nil {
+		return err
+	}
+	fmt.Printf("%s has been deleted\n", name)
+	return nil
+}
+
+// templateUsers returns the names of the VMs in datacenter with a disk that
+// is a linked clone of a disk of template.
+func templateUsers(ctx context.Context, client *govmomi.Client, datacenter *object.Datacenter, template *object.VirtualMachine) ([]string, error) {
+	var mtemplate mo.VirtualMachine
+	if err := template.Properties(ctx, template.Reference(), []string{"layoutEx"}, &mtemplate); err != nil {
+		return nil, err
+	}
+	disks := map[string]bool{}
+	if mtemplate.LayoutEx != nil {
+		for _, file := range mtemplate.LayoutEx.File {
+			if file.Type == string(types.VirtualMachineFileLayoutExFileTypeDiskDescriptor) {
+				disks[file.Name] = true
+			}
+		}
+	}
+
+	v, err := view.NewManager(client.Client).CreateContainerView(ctx, datacenter.Reference(), []string{"VirtualMachine"}, true)
+	if err != nil {
+		return nil, err
+	}
+	defer v.Destroy(ctx)
+
+	var vms []mo.VirtualMachine
+	if err := v.Retrieve(ctx, []string{"VirtualMachine"}, []string{"name", "config.hardware.device"}, &vms); err != nil {
+		return nil, err
+	}
+
+	users := []string{}
+	for _, vm := range vms {
+		if vm.Self == template.Reference() || vm.Config == nil {
+			continue
+		}
+		for _, device := range vm.Config.Hardware.Device {
+			if disk, ok := device.(*types.VirtualDisk); ok && diskParentIn(disk.Backing, disks) {
+				users = append(users, vm.Name)
+				break
+			}
+		}
+	}
+	return users, nil
+}
+
+// diskParentIn tells whether a disk in the parent chain of backing is one of
+// disks.
+func diskParentIn(backing types.BaseVirtualDeviceBackingInfo, disks map[string]bool) bool {
+	for {
+		switch b := backing.(type) {
+		case *types.VirtualDiskFlatVer2BackingInfo:
+			if b.Parent == nil {
+				return false
+			}
+			if disks[b.Parent.FileName] {
+				return true
+			}
+			backing = b.Parent
+		case *types.VirtualDiskSeSparseBackingInfo:
+			if b.Parent == nil {
+				return false
+			}
+			if disks[b.Parent.FileName] {
+				return true
+			}
+			backing = b.Parent
+		case *types.VirtualDiskSparseVer2BackingInfo:
+			if b.Parent == nil {
+				return false
+			}
+			if disks[b.Parent.FileName] {
+				return true
+			}
+			backing = b.Parent
+		default:
+			return false
+		}
+	}
+}
@@ -0,0 +1,62 @@
+// Copyright © 2019 Jeff Wu <jeff.wu.junfei@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package driver
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"fmt"
+	"strings"
+
+	"github.com/jeffwubj/kubev/pkg/kubev/model"
+	"github.com/vmware/govmomi/vim25/soap"
+)
+
+// configureTLS makes c trust the CA bundle and the pinned thumbprint of
+// answers, the thumbprint is accepted for a certificate the system does not
+// trust.
+func configureTLS(c *soap.Client, answers *model.Answers) error {
+	if answers.CABundle != "" {
+		if err := c.SetRootCAs(answers.CABundle); err != nil {
+			return err
+		}
+	}
+	if answers.Thumbprint != "" {
+		c.SetThumbprint(serverURL(answers).Host, answers.Thumbprint)
+	}
+	return nil
+}
+
+// ServerThumbprints returns the SHA-1 and SHA-256 thumbprints of the
+// certificate of the vCenter or ESX in answers, without verifying it.
+func ServerThumbprints(answers *model.Answers) (string, string, error) {
+	conn, err := tls.Dial("tcp", serverURL(answers).Host, &tls.Config{InsecureSkipVerify: true})
+	if err != nil {
+		return "", "", err
+	}
+	defer conn.Close()
+
+	certs := conn.ConnectionState().PeerCertificates
+	if len(certs) == 0 {
+		return "", "", fmt.Errorf("%s did not present a certificate", answers.Serverurl)
+	}
+
+	sum := sha256.Sum256(certs[0].Raw)
+	hex := make([]string, len(sum))
+	for i, b := range sum {
+		hex[i] = fmt.Sprintf("%02X", b)
+	}
+	return soap.ThumbprintSHA1(certs[0]), strings.Join(hex, ":"), nil
+}
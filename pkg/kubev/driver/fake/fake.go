@@ -0,0 +1,225 @@
+// Copyright © 2019 Jeff Wu <jeff.wu.junfei@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package fake provides a Provider that keeps its nodes in memory, so the
+// deployer can be tested without an infrastructure.
+package fake
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"github.com/jeffwubj/kubev/pkg/kubev/constants"
+	"github.com/jeffwubj/kubev/pkg/kubev/driver"
+	"github.com/jeffwubj/kubev/pkg/kubev/model"
+)
+
+// JoinCommand is what the fake kubeadm prints for workers to join.
+const JoinCommand = "kubeadm join 10.0.0.1:6443 --token kubev.fake --discovery-token-unsafe-skip-ca-verification"
+
+// AdminConfig is the kubeconfig the fake master holds.
+const AdminConfig = `apiVersion: v1
+kind: Config
+clusters:
+- cluster:
+    server: https://10.0.0.1:6443
+  name: kubernetes
+contexts:
+- context:
+    cluster: kubernetes
+    user: kubernetes-admin
+  name: kubernetes-admin@kubernetes
+current-context: kubernetes-admin@kubernetes
+users:
+- name: kubernetes-admin
+  user:
+    token: fake
+`
+
+// Provider keeps the VMs it creates in memory and runs guest commands by
+// recording them. It implements GuestOperator so the deployer can use the
+// tools command channel.
+type Provider struct {
+	// VMs holds the created nodes by VM name.
+	VMs map[string]*model.K8sNode
+	// Commands holds the commands run in each VM, by VM name.
+	Commands map[string][]string
+	// Files holds the files uploaded to each VM, by VM name and path.
+	Files map[string]map[string][]byte
+	// Closed is set once Close was called.
+	Closed bool
+
+	nextIP int
+}
+
+var (
+	_ driver.Provider      = &Provider{}
+	_ driver.GuestOperator = &Provider{}
+)
+
+// NewProvider returns a fake provider without VMs.
+func NewProvider() *Provider {
+	return &Provider{
+		VMs:      map[string]*model.K8sNode{},
+		Commands: map[string][]string{},
+		Files:    map[string]map[string][]byte{},
+	}
+}
+
+func (p *Provider) Validate() error {
+	return nil
+}
+
+// CreateNode records a copy of node, nodes without a static IP get the next
+// free one.
+func (p *Provider) CreateNode(node *model.K8sNode, pool *model.NodePool) error {
+	if _, ok := p.VMs[node.VMName]; ok {
+		return fmt.Errorf("%s already exists", node.VMName)
+	}
+	if node.IP == "" {
+		p.nextIP++
+		node.IP = fmt.Sprintf("10.0.0.%d", p.nextIP)
+	}
+	node.Mo = "vm-" + node.VMName
+	node.PowerState = constants.PoweredOn
+	vm := *node
+	p.VMs[node.VMName] = &vm
+	return nil
+}
+
+func (p *Provider) PowerOnNode(node *model.K8sNode) error {
+	return p.setPowerState(node, constants.PoweredOn)
+}
+
+func (p *Provider) PowerOffNode(node *model.K8sNode) error {
+	return p.setPowerState(node, constants.PoweredOff)
+}
+
+func (p *Provider) ShutdownNode(node *model.K8sNode) error {
+	return p.setPowerState(node, constants.PoweredOff)
+}
+
+func (p *Provider) setPowerState(node *model.K8sNode, state string) error {
+	vm, err := p.vm(node)
+	if err != nil {
+		return err
+	}
+	vm.PowerState = state
+	return nil
+}
+
+// DeleteNode removes node, a node that does not exist is ignored like VMs
+// that were deleted by hand.
+func (p *Provider) DeleteNode(node *model.K8sNode) error {
+	delete(p.VMs, node.VMName)
+	return nil
+}
+
+func (p *Provider) GetNodeIP(node *model.K8sNode) (string, error) {
+	vm, err := p.vm(node)
+	if err != nil {
+		return "", err
+	}
+	return vm.IP, nil
+}
+
+func (p *Provider) FindMasterNodes() ([]*model.K8sNode, error) {
+	masters := []*model.K8sNode{}
+	for _, vm := range p.VMs {
+		if vm.MasterNode {
+			master := *vm
+			masters = append(masters, &master)
+		}
+	}
+	return masters, nil
+}
+
+func (p *Provider) FindMasterNode(clusterID string) (*model.K8sNode, error) {
+	for _, vm := range p.VMs {
+		if vm.MasterNode && vm.ClusterID == clusterID {
+			master := *vm
+			return &master, nil
+		}
+	}
+	return nil, nil
+}
+
+func (p *Provider) FindClusterNodes(clusterID string) ([]*model.K8sNode, error) {
+	nodes := []*model.K8sNode{}
+	for _, vm := range p.VMs {
+		if vm.ClusterID == clusterID {
+			node := *vm
+			nodes = append(nodes, &node)
+		}
+	}
+	return nodes, nil
+}
+
+func (p *Provider) Close() error {
+	p.Closed = true
+	return nil
+}
+
+// GuestOperations returns a guest that records what is run in node.
+func (p *Provider) GuestOperations(node *model.K8sNode) (driver.GuestOperations, error) {
+	if _, err := p.vm(node); err != nil {
+		return nil, err
+	}
+	return &guest{p, node.VMName}, nil
+}
+
+func (p *Provider) vm(node *model.K8sNode) (*model.K8sNode, error) {
+	vm, ok := p.VMs[node.VMName]
+	if !ok {
+		return nil, fmt.Errorf("Cannot find VM %s", node.VMName)
+	}
+	return vm, nil
+}
+
+type guest struct {
+	p    *Provider
+	name string
+}
+
+// Run answers the commands of kubeadm the deployer reads the output of,
+// every other command succeeds without output.
+func (g *guest) Run(cmd string) (int, []byte, error) {
+	g.p.Commands[g.name] = append(g.p.Commands[g.name], cmd)
+	switch {
+	case cmd == constants.KubeAdmInit:
+		return 0, []byte("Your Kubernetes master has initialized successfully!\n\n  " + JoinCommand + "\n"), nil
+	case cmd == constants.KubeAdmJoin:
+		return 0, []byte(JoinCommand), nil
+	case strings.HasPrefix(cmd, "cat /etc/kubernetes/admin.conf"):
+		return 0, []byte(AdminConfig), nil
+	}
+	return 0, nil, nil
+}
+
+func (g *guest) Upload(r io.Reader, size int64, path string, mode int64) error {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	if int64(len(data)) != size {
+		return fmt.Errorf("Uploaded %d bytes to %s, expected %d", len(data), path, size)
+	}
+	if g.p.Files[g.name] == nil {
+		g.p.Files[g.name] = map[string][]byte{}
+	}
+	g.p.Files[g.name][path] = data
+	return nil
+}
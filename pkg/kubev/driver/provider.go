@@ -0,0 +1,188 @@
+// Copyright © 2019 Jeff Wu <jeff.wu.junfei@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package driver
+
+import (
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/jeffwubj/kubev/pkg/kubev/constants"
+	"github.com/jeffwubj/kubev/pkg/kubev/model"
+)
+
+// Provider is the infrastructure Kubernetes nodes are deployed to. What only
+// some infrastructures can do is left to the optional interfaces below,
+// callers check for them with a type assertion.
+type Provider interface {
+	// Validate checks the account in answers and fills in what can be
+	// detected from the infrastructure, e.g. whether it is a vCenter.
+	Validate() error
+	// CreateNode creates and powers on the VM for node sized and placed
+	// as pool says, with cloud-init data that lets the kubev SSH key in,
+	// then records its IP and reference in node.
+	CreateNode(node *model.K8sNode, pool *model.NodePool) error
+	PowerOnNode(node *model.K8sNode) error
+	PowerOffNode(node *model.K8sNode) error
+	// ShutdownNode shuts the guest OS down, falling back to a power off.
+	ShutdownNode(node *model.K8sNode) error
+	DeleteNode(node *model.K8sNode) error
+	GetNodeIP(node *model.K8sNode) (string, error)
+	// FindMasterNodes discovers the master nodes of all clusters deployed
+	// by kubev, without their IPs.
+	FindMasterNodes() ([]*model.K8sNode, error)
+	// FindMasterNode returns the master node of cluster clusterID with its
+	// IP, or nil without error if there is none. Clusters have no ID where
+	// the provider cannot tell them apart.
+	FindMasterNode(clusterID string) (*model.K8sNode, error)
+	// FindClusterNodes discovers all nodes of the cluster clusterID, it
+	// returns nil if the provider cannot tell clusters apart.
+	FindClusterNodes(clusterID string) ([]*model.K8sNode, error)
+	// Close releases the connection to the infrastructure, the provider
+	// cannot be used afterwards.
+	Close() error
+}
+
+// Bootstrapper is a Provider that can tell whether the guest OS applied the
+// cloud-init data CreateNode gave it.
+type Bootstrapper interface {
+	// BootstrapApplied tells whether the guest OS of node applied its
+	// cloud-init data, going by the host name it reports.
+	BootstrapApplied(node *model.K8sNode) (bool, error)
+	// ClearBootstrap removes the cloud-init userdata of node once it was
+	// applied.
+	ClearBootstrap(node *model.K8sNode) error
+}
+
+// Placer is a Provider that can keep the nodes of a cluster apart.
+type Placer interface {
+	// UpdatePlacement makes the infrastructure spread the nodes of k8snodes
+	// and restart the master first after a failure, it is called whenever
+	// nodes are added or removed.
+	UpdatePlacement(k8snodes *model.K8sNodes) error
+	// RemovePlacement removes what UpdatePlacement created for clusterID.
+	RemovePlacement(clusterID string) error
+}
+
+// PreflightChecker is a Provider that can check a deployment up front.
+type PreflightChecker interface {
+	// Preflight checks whether the nodes of controlplane and pools can be
+	// deployed, without changing anything.
+	Preflight(controlplane *model.NodePool, pools []*model.NodePool) ([]*model.PreflightCheck, error)
+}
+
+// InventoryPreparer is a Provider that creates the folders and resource
+// pools the nodes go to.
+type InventoryPreparer interface {
+	// PrepareFolder creates the missing parts of the VM folder and returns
+	// the paths it created, parents first.
+	PrepareFolder() ([]string, error)
+	// PrepareResourcePool creates the missing parts of resource pool name
+	// and returns the paths it created, parents first.
+	PrepareResourcePool(name string) ([]string, error)
+	// RemoveCreated removes the folders and resource pools kubev created
+	// for k8snodes which are empty now.
+	RemoveCreated(k8snodes *model.K8sNodes) error
+}
+
+// Snapshotter is a Provider that can snapshot nodes.
+type Snapshotter interface {
+	// CreateSnapshot snapshots the VM of node, with the memory of the
+	// running VM or with the guest file systems quiesced.
+	CreateSnapshot(node *model.K8sNode, name, description string, memory, quiesce bool) error
+	ListSnapshots(node *model.K8sNode) ([]*model.Snapshot, error)
+	// RevertSnapshot restores node to snapshot name without powering it on.
+	RevertSnapshot(node *model.K8sNode, name string) error
+	DeleteSnapshot(node *model.K8sNode, name string) error
+}
+
+// EventSource is a Provider that records what happened to the nodes.
+type EventSource interface {
+	// Events calls f with the last count events and finished tasks of each
+	// VM of k8snodes and of the template, oldest first. If stop is not nil
+	// it goes on with new ones until stop is closed.
+	Events(k8snodes *model.K8sNodes, count int, stop <-chan struct{}, f func(*model.Event) error) error
+}
+
+// HardwareChecker is a Provider that can compare nodes with the hardware
+// profile.
+type HardwareChecker interface {
+	// HardwareMismatches lists how the VM of node differs from the hardware
+	// profile, empty if it matches.
+	HardwareMismatches(node *model.K8sNode) ([]string, error)
+}
+
+// GuestOperator is a Provider that reaches into the guest OS of nodes
+// without the node network.
+type GuestOperator interface {
+	// GuestOperations returns a channel into the guest OS of node through
+	// VMware Tools, it does not need the node network.
+	GuestOperations(node *model.K8sNode) (GuestOperations, error)
+}
+
+// TemplateManager is a Provider that clones nodes from templates it keeps.
+type TemplateManager interface {
+	// ListTemplates returns the templates kubev created to clone nodes from.
+	ListTemplates() ([]*model.Template, error)
+	// DeleteTemplate deletes the template called name, a template nodes
+	// are still linked clones of only if force is set.
+	DeleteTemplate(name string, force bool) error
+}
+
+// GuestOperations runs programs in the guest OS of a node and copies files
+// into it, as root.
+type GuestOperations interface {
+	// Run runs cmd by the shell, waits for it to exit and returns its exit
+	// code and combined standard output and standard error.
+	Run(cmd string) (int, []byte, error)
+	// Upload writes size bytes from r to path with permissions mode, an
+	// existing file is overwritten.
+	Upload(r io.Reader, size int64, path string, mode int64) error
+}
+
+// ProviderFactory creates a Provider for answers.
+type ProviderFactory func(answers *model.Answers) (Provider, error)
+
+var providers = map[string]ProviderFactory{}
+
+// RegisterProvider makes a provider available under name, it is meant to be
+// called from init().
+func RegisterProvider(name string, factory ProviderFactory) {
+	providers[name] = factory
+}
+
+// Providers returns the names of all registered providers.
+func Providers() []string {
+	names := []string{}
+	for name := range providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NewProvider returns the provider selected by answers.Provider, vSphere is
+// used if it is not set.
+func NewProvider(answers *model.Answers) (Provider, error) {
+	name := answers.Provider
+	if name == "" {
+		name = constants.DefaultProvider
+	}
+	factory, ok := providers[name]
+	if !ok {
+		return nil, fmt.Errorf("Unknown provider %s, available providers are %v", name, Providers())
+	}
+	return factory(answers)
+}
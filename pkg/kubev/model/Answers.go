@@ -15,13 +15,19 @@
 package model
 
 type Answers struct {
+	Provider          string
 	Serverurl         string
 	Port              int
 	Username          string
 	Password          string
+	Insecure          bool
+	Thumbprint        string
+	CABundle          string
 	IsVCenter         bool
 	Datacenter        string
 	Datastore         string
+	StoragePolicy     string
+	DatastoreCluster  string
 	Resourcepool      string
 	Folder            string
 	Cpu               int
@@ -29,4 +35,31 @@ type Answers struct {
 	Network           string
 	KubernetesVersion string
 	WorkerNodes       int
+	LinkedClone       bool
+	ContentLibrary    string
+	LibraryItem       string
+	// StreamOVA imports the OVA straight from its download URL when it
+	// is not in the local cache
+	StreamOVA bool
+	// CommandChannel is how commands get into the nodes, over SSH or
+	// through VMware Tools when the node network cannot be reached
+	CommandChannel string
+	// PoolAllocation is applied if kubev creates the resource pool, nil
+	// for no reservations and limits
+	PoolAllocation *ResourceAllocation
+	// HardwareProfile is applied to every node, nil to keep the hardware
+	// of the template
+	HardwareProfile *HardwareProfile
+	// IPPool is nil when node addresses come from DHCP
+	IPPool *IPPool
+	// ControlPlane sizes the master node, nil to use Cpu and Memory
+	ControlPlane *NodePool
+	// NodePools holds the worker pools, a single pool of WorkerNodes
+	// replicas is used when it is empty
+	NodePools []*NodePool
+	// FailureDomains are what the nodes of every pool are spread across,
+	// nodes are placed by their pool only when it is empty
+	FailureDomains []*FailureDomain
+	// DomainPlacement is roundrobin or weighted
+	DomainPlacement string
 }
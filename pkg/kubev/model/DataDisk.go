@@ -0,0 +1,27 @@
+// Copyright © 2019 Jeff Wu <jeff.wu.junfei@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package model
+
+// DataDisk is an extra disk added to a node and mounted at MountPoint.
+type DataDisk struct {
+	// Size is in GB
+	Size int
+	// Datastore is empty to place the disk next to the VM
+	Datastore  string
+	MountPoint string
+	// UUID is the vSphere UUID of the disk on a node, the guest finds the
+	// disk by it
+	UUID string
+}
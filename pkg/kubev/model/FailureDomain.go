@@ -0,0 +1,51 @@
+// Copyright © 2019 Jeff Wu <jeff.wu.junfei@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package model
+
+import "path"
+
+// FailureDomain is a part of the infrastructure that fails independently of
+// the others, a compute cluster or standalone host with the datastore and
+// network its nodes use. Its name is the zone of its Kubernetes nodes.
+type FailureDomain struct {
+	Name string
+	// ComputeResource is the compute cluster or standalone host, nodes go to
+	// its root resource pool unless Resourcepool is set
+	ComputeResource string
+	Resourcepool    string
+	// Datastore and Network replace those of the node pool, empty to keep
+	// them. Nodes with static IPs cannot change network, the IP pool is of
+	// the network of the cluster
+	Datastore string
+	Network   string
+	// NodeSubnet replaces the node subnet of the pool, for a Network on
+	// another subnet
+	NodeSubnet string
+	// Weight is the share of nodes the domain gets with weighted
+	// placement, 0 counts as 1
+	Weight int
+}
+
+// ResourcePoolPath returns the resource pool the nodes of the domain go to,
+// empty if the domain does not say.
+func (d *FailureDomain) ResourcePoolPath() string {
+	if d.Resourcepool != "" {
+		return d.Resourcepool
+	}
+	if d.ComputeResource != "" {
+		return path.Join(d.ComputeResource, "Resources")
+	}
+	return ""
+}
@@ -0,0 +1,49 @@
+// Copyright © 2019 Jeff Wu <jeff.wu.junfei@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package model
+
+import "github.com/jeffwubj/kubev/pkg/kubev/constants"
+
+// HardwareProfile is the virtual hardware nodes are reconfigured to, empty
+// fields keep what the template has.
+type HardwareProfile struct {
+	// Version is the lowest VM hardware version, Ex: vmx-15
+	Version string
+	// NICType is the adapter type of all NICs, Ex: vmxnet3
+	NICType string
+	// SCSIType is the type of the SCSI controller, Ex: pvscsi
+	SCSIType    string
+	ExtraConfig []*ExtraConfig
+}
+
+// ExtraConfig is an advanced setting of the VM, a list of them is kept as
+// keys like disk.EnableUUID do not survive the config file as map keys.
+type ExtraConfig struct {
+	Key   string
+	Value string
+}
+
+// NewCSIHardwareProfile returns the hardware the vSphere CSI driver and
+// cloud provider need.
+func NewCSIHardwareProfile() *HardwareProfile {
+	return &HardwareProfile{
+		Version:  constants.CSIHardwareVersion,
+		NICType:  constants.CSINICType,
+		SCSIType: constants.CSISCSIType,
+		ExtraConfig: []*ExtraConfig{
+			{Key: "disk.EnableUUID", Value: "TRUE"},
+		},
+	}
+}
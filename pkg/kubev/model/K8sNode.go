@@ -18,9 +18,19 @@ type K8sNodes struct {
 	JoinString  string
 	MasterNode  *K8sNode
 	WorkerNodes []*K8sNode
+	// ControlPlane and NodePools are the pools the cluster was deployed
+	// with, Replicas follows kubev scale
+	ControlPlane *NodePool
+	NodePools    []*NodePool
+	// CreatedFolders and CreatedResourcePools are the inventory paths kubev
+	// created for the cluster, parents first, destory removes them once
+	// they are empty
+	CreatedFolders       []string
+	CreatedResourcePools []string
 }
 
 type K8sNode struct {
+	ClusterID      string
 	VMName         string
 	IP             string
 	Mo             string
@@ -29,4 +39,60 @@ type K8sNode struct {
 	DatastoreName  string
 	MasterNode     bool
 	Ready          bool
+	// StaticIP is set when IP was allocated from Answers.IPPool instead of DHCP
+	StaticIP bool
+	// CloneMode is how the VM was provisioned, full, linked or copy, nodes
+	// on ESX deployed by older versions have ova
+	CloneMode string
+	// Pool is the name of the node pool the node belongs to
+	Pool string
+	// NodeNetwork and NodeSubnet select the node IP among the addresses
+	// of the VM
+	NodeNetwork string
+	NodeSubnet  string
+	// DiskSize and DataDisks are what the node was created with, the
+	// guest grows its root file system and mounts the data disks
+	DiskSize  int
+	DataDisks []*DataDisk
+	// StoragePolicy is the SPBM policy the disks of the VM were placed with
+	StoragePolicy string
+	// PowerState is poweredOff after kubev stop and poweredOn after kubev
+	// start, empty if neither ran
+	PowerState string
+	// FailureDomain is the failure domain the node was placed in, also
+	// the zone labels of the Kubernetes node
+	FailureDomain string
+}
+
+// AllNodes returns the master node followed by all worker nodes.
+func (k *K8sNodes) AllNodes() []*K8sNode {
+	nodes := []*K8sNode{}
+	if k == nil {
+		return nodes
+	}
+	if k.MasterNode != nil {
+		nodes = append(nodes, k.MasterNode)
+	}
+	return append(nodes, k.WorkerNodes...)
+}
+
+// NodePool returns the pool called name, or nil if there is none.
+func (k *K8sNodes) NodePool(name string) *NodePool {
+	for _, pool := range k.NodePools {
+		if pool.Name == name {
+			return pool
+		}
+	}
+	return nil
+}
+
+// PoolNodes returns the worker nodes of pool name.
+func (k *K8sNodes) PoolNodes(name string) []*K8sNode {
+	nodes := []*K8sNode{}
+	for _, node := range k.WorkerNodes {
+		if node.Pool == name {
+			nodes = append(nodes, node)
+		}
+	}
+	return nodes
 }
@@ -0,0 +1,41 @@
+// Copyright © 2019 Jeff Wu <jeff.wu.junfei@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package model
+
+// NodePool is a group of nodes sharing the same sizing and placement, empty
+// fields fall back to the values in Answers.
+type NodePool struct {
+	Name   string
+	Cpu    int
+	Memory int
+	// DiskSize is the size of the root disk in GB, 0 keeps the template's
+	DiskSize  int
+	Datastore string
+	// StoragePolicy places the nodes on a datastore compatible with the
+	// SPBM policy, DatastoreCluster lets Storage DRS pick the datastore
+	StoragePolicy    string
+	DatastoreCluster string
+	// Network is the Kubernetes node network, the first NIC connects to it
+	Network string
+	// ExtraNetworks get one more NIC each, they can be port groups of
+	// standard or distributed switches alike
+	ExtraNetworks []string
+	// NodeSubnet picks the node IP by subnet instead of by network, Ex:
+	// 10.192.10.0/24
+	NodeSubnet   string
+	Resourcepool string
+	Replicas     int
+	DataDisks    []*DataDisk
+}
@@ -0,0 +1,27 @@
+// Copyright © 2019 Jeff Wu <jeff.wu.junfei@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package model
+
+// Template is a VM template kubev clones Kubernetes nodes from.
+type Template struct {
+	Name         string
+	OSVersion    string
+	KubeVVersion string
+	// LibraryItem and LibraryVersion are the ID and content version of the
+	// Content Library item the template was deployed from, empty if it was
+	// imported from the OVA
+	LibraryItem    string
+	LibraryVersion string
+}
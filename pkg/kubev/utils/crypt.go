@@ -0,0 +1,146 @@
+// Copyright © 2019 Jeff Wu <jeff.wu.junfei@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"crypto/rand"
+	"crypto/sha512"
+)
+
+// cryptAlphabet holds the characters crypt(3) encodes hashes and salts in.
+const cryptAlphabet = "./0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+const sha512CryptRounds = 5000
+
+// sha512CryptOrder lists the bytes of the final digest in the groups of
+// three SHA512Crypt encodes them in.
+var sha512CryptOrder = [][3]int{
+	{0, 21, 42}, {22, 43, 1}, {44, 2, 23}, {3, 24, 45}, {25, 46, 4},
+	{47, 5, 26}, {6, 27, 48}, {28, 49, 7}, {50, 8, 29}, {9, 30, 51},
+	{31, 52, 10}, {53, 11, 32}, {12, 33, 54}, {34, 55, 13}, {56, 14, 35},
+	{15, 36, 57}, {37, 58, 16}, {59, 17, 38}, {18, 39, 60}, {40, 61, 19},
+	{62, 20, 41},
+}
+
+// NewPassword returns a random password of n characters that needs no
+// quoting, which also makes a valid crypt(3) salt.
+func NewPassword(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	for i := range b {
+		b[i] = cryptAlphabet[int(b[i])%len(cryptAlphabet)]
+	}
+	return string(b), nil
+}
+
+// SHA512Crypt hashes password with salt the way crypt(3) does for "$6$",
+// so the hash can be put into /etc/shadow instead of the password.
+func SHA512Crypt(password, salt string) string {
+	if len(salt) > 16 {
+		salt = salt[:16]
+	}
+	p := []byte(password)
+	s := []byte(salt)
+
+	h := sha512.New()
+	h.Write(p)
+	h.Write(s)
+	h.Write(p)
+	b := h.Sum(nil)
+
+	h = sha512.New()
+	h.Write(p)
+	h.Write(s)
+	for i := len(p); i > 0; i -= sha512.Size {
+		if i > sha512.Size {
+			h.Write(b)
+		} else {
+			h.Write(b[:i])
+		}
+	}
+	for i := len(p); i > 0; i >>= 1 {
+		if i&1 != 0 {
+			h.Write(b)
+		} else {
+			h.Write(p)
+		}
+	}
+	a := h.Sum(nil)
+
+	h = sha512.New()
+	for i := 0; i < len(p); i++ {
+		h.Write(p)
+	}
+	pSeq := repeatBytes(h.Sum(nil), len(p))
+
+	h = sha512.New()
+	for i := 0; i < 16+int(a[0]); i++ {
+		h.Write(s)
+	}
+	sSeq := repeatBytes(h.Sum(nil), len(s))
+
+	c := a
+	for r := 0; r < sha512CryptRounds; r++ {
+		h = sha512.New()
+		if r&1 != 0 {
+			h.Write(pSeq)
+		} else {
+			h.Write(c)
+		}
+		if r%3 != 0 {
+			h.Write(sSeq)
+		}
+		if r%7 != 0 {
+			h.Write(pSeq)
+		}
+		if r&1 != 0 {
+			h.Write(c)
+		} else {
+			h.Write(pSeq)
+		}
+		c = h.Sum(nil)
+	}
+
+	hash := []byte("$6$" + salt + "$")
+	for _, group := range sha512CryptOrder {
+		hash = appendCrypt64(hash, uint(c[group[0]])<<16|uint(c[group[1]])<<8|uint(c[group[2]]), 4)
+	}
+	hash = appendCrypt64(hash, uint(c[63]), 2)
+	return string(hash)
+}
+
+// repeatBytes repeats b until it is n bytes long.
+func repeatBytes(b []byte, n int) []byte {
+	seq := make([]byte, 0, n)
+	for len(seq) < n {
+		if n-len(seq) < len(b) {
+			b = b[:n-len(seq)]
+		}
+		seq = append(seq, b...)
+	}
+	return seq
+}
+
+// appendCrypt64 appends the n lowest six-bit groups of w to hash, lowest
+// first.
+func appendCrypt64(hash []byte, w uint, n int) []byte {
+	for i := 0; i < n; i++ {
+		hash = append(hash, cryptAlphabet[w&0x3f])
+		w >>= 6
+	}
+	return hash
+}
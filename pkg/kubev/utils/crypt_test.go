@@ -0,0 +1,51 @@
+// Copyright © 2019 Jeff Wu <jeff.wu.junfei@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSHA512Crypt(t *testing.T) {
+	tests := []struct {
+		password string
+		salt     string
+		hash     string
+	}{
+		{"Hello world!", "saltstring", "$6$saltstring$svn8UoSVapNtMuq1ukKS4tPQd8iKwSMHWjl/O817G3uBnIFNjnQJuesI68u4OTLiBFdcbYEdFCoEOfaS35inz1"},
+		{"This is just a test", "toolongsaltstring", "$6$toolongsaltstrin$lQ8jolhgVRVhY4b5pZKaysCLi0QBxGoNeKQzQ3glMhwllF7oGDZxUhx1yxdYcz/e1JSbq3y6JMxxl8audkUEm0"},
+	}
+	for _, test := range tests {
+		if hash := SHA512Crypt(test.password, test.salt); hash != test.hash {
+			t.Errorf("SHA512Crypt(%q, %q) = %s, want %s", test.password, test.salt, hash, test.hash)
+		}
+	}
+}
+
+func TestNewPassword(t *testing.T) {
+	password, err := NewPassword(24)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(password) != 24 {
+		t.Errorf("NewPassword(24) returned %d characters", len(password))
+	}
+	for _, c := range password {
+		if !strings.ContainsRune(cryptAlphabet, c) {
+			t.Errorf("NewPassword(24) = %s, %q is not in the crypt alphabet", password, c)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+// Copyright © 2019 Jeff Wu <jeff.wu.junfei@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+
+	"github.com/jeffwubj/kubev/pkg/kubev/model"
+)
+
+// ValidateIPPool checks that the range and gateway of pool are inside its
+// CIDR, and that the range holds neither the network nor the broadcast
+// address of it.
+func ValidateIPPool(pool *model.IPPool) error {
+	_, ipnet, err := net.ParseCIDR(pool.CIDR)
+	if err != nil {
+		return fmt.Errorf("Invalid CIDR %s", pool.CIDR)
+	}
+	for _, addr := range []string{pool.Gateway, pool.RangeStart, pool.RangeEnd} {
+		ip := net.ParseIP(addr).To4()
+		if ip == nil {
+			return fmt.Errorf("Invalid IPv4 address %s", addr)
+		}
+		if !ipnet.Contains(ip) {
+			return fmt.Errorf("%s is not in %s", addr, pool.CIDR)
+		}
+	}
+	for _, addr := range pool.DNS {
+		if net.ParseIP(addr) == nil {
+			return fmt.Errorf("Invalid DNS server %s", addr)
+		}
+	}
+	start := ipToUint32(net.ParseIP(pool.RangeStart))
+	end := ipToUint32(net.ParseIP(pool.RangeEnd))
+	if start > end {
+		return fmt.Errorf("Range start %s is after range end %s", pool.RangeStart, pool.RangeEnd)
+	}
+	// /31 and /32 networks have no network and broadcast addresses
+	if ones, bits := ipnet.Mask.Size(); bits-ones > 1 {
+		network := ipToUint32(ipnet.IP)
+		broadcast := network | ^ipToUint32(net.IP(ipnet.Mask))
+		for _, reserved := range []uint32{network, broadcast} {
+			if start <= reserved && reserved <= end {
+				return fmt.Errorf("Range %s-%s holds %s, the network or broadcast address of %s", pool.RangeStart, pool.RangeEnd, uint32ToIP(reserved), pool.CIDR)
+			}
+		}
+	}
+	return nil
+}
+
+// AllocateIP returns the first address of pool which is not held by any node
+// in k8snodes. Addresses are released back to the pool once their node is
+// removed from k8snodes.
+func AllocateIP(pool *model.IPPool, k8snodes *model.K8sNodes) (string, error) {
+	if err := ValidateIPPool(pool); err != nil {
+		return "", err
+	}
+
+	used := map[string]bool{pool.Gateway: true}
+	for _, node := range k8snodes.AllNodes() {
+		if node.StaticIP {
+			used[node.IP] = true
+		}
+	}
+
+	start := ipToUint32(net.ParseIP(pool.RangeStart))
+	end := ipToUint32(net.ParseIP(pool.RangeEnd))
+	for i := start; i <= end && i >= start; i++ {
+		ip := uint32ToIP(i).String()
+		if !used[ip] {
+			return ip, nil
+		}
+	}
+	return "", fmt.Errorf("No free address left in %s-%s", pool.RangeStart, pool.RangeEnd)
+}
+
+// IPPoolSubnetMask returns the dotted subnet mask of pool, e.g. 255.255.255.0.
+func IPPoolSubnetMask(pool *model.IPPool) (string, error) {
+	_, ipnet, err := net.ParseCIDR(pool.CIDR)
+	if err != nil {
+		return "", fmt.Errorf("Invalid CIDR %s", pool.CIDR)
+	}
+	return net.IP(ipnet.Mask).String(), nil
+}
+
+func ipToUint32(ip net.IP) uint32 {
+	return binary.BigEndian.Uint32(ip.To4())
+}
+
+func uint32ToIP(i uint32) net.IP {
+	ip := make(net.IP, net.IPv4len)
+	binary.BigEndian.PutUint32(ip, i)
+	return ip
+}
@@ -0,0 +1,93 @@
+// Copyright © 2019 Jeff Wu <jeff.wu.junfei@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"testing"
+
+	"github.com/jeffwubj/kubev/pkg/kubev/model"
+)
+
+func TestValidateIPPool(t *testing.T) {
+	tests := []struct {
+		name  string
+		pool  model.IPPool
+		valid bool
+	}{
+		{"range inside /24", model.IPPool{CIDR: "10.0.0.0/24", Gateway: "10.0.0.1", RangeStart: "10.0.0.10", RangeEnd: "10.0.0.20"}, true},
+		{"gateway inside range", model.IPPool{CIDR: "10.0.0.0/24", Gateway: "10.0.0.15", RangeStart: "10.0.0.10", RangeEnd: "10.0.0.20"}, true},
+		{"whole /31", model.IPPool{CIDR: "10.0.0.0/31", Gateway: "10.0.0.0", RangeStart: "10.0.0.0", RangeEnd: "10.0.0.1"}, true},
+		{"/32", model.IPPool{CIDR: "10.0.0.5/32", Gateway: "10.0.0.5", RangeStart: "10.0.0.5", RangeEnd: "10.0.0.5"}, true},
+		{"network address", model.IPPool{CIDR: "10.0.0.0/24", Gateway: "10.0.0.1", RangeStart: "10.0.0.0", RangeEnd: "10.0.0.20"}, false},
+		{"broadcast address", model.IPPool{CIDR: "10.0.0.0/24", Gateway: "10.0.0.1", RangeStart: "10.0.0.200", RangeEnd: "10.0.0.255"}, false},
+		{"broadcast of /30", model.IPPool{CIDR: "10.0.0.4/30", Gateway: "10.0.0.5", RangeStart: "10.0.0.6", RangeEnd: "10.0.0.7"}, false},
+		{"range outside CIDR", model.IPPool{CIDR: "10.0.0.0/24", Gateway: "10.0.0.1", RangeStart: "10.0.0.10", RangeEnd: "10.0.1.20"}, false},
+		{"gateway outside CIDR", model.IPPool{CIDR: "10.0.0.0/24", Gateway: "10.0.1.1", RangeStart: "10.0.0.10", RangeEnd: "10.0.0.20"}, false},
+		{"start after end", model.IPPool{CIDR: "10.0.0.0/24", Gateway: "10.0.0.1", RangeStart: "10.0.0.20", RangeEnd: "10.0.0.10"}, false},
+		{"invalid CIDR", model.IPPool{CIDR: "10.0.0.0/33", Gateway: "10.0.0.1", RangeStart: "10.0.0.10", RangeEnd: "10.0.0.20"}, false},
+		{"invalid DNS", model.IPPool{CIDR: "10.0.0.0/24", Gateway: "10.0.0.1", DNS: []string{"dns"}, RangeStart: "10.0.0.10", RangeEnd: "10.0.0.20"}, false},
+	}
+	for _, test := range tests {
+		err := ValidateIPPool(&test.pool)
+		if test.valid && err != nil {
+			t.Errorf("%s: unexpected error: %s", test.name, err.Error())
+		}
+		if !test.valid && err == nil {
+			t.Errorf("%s: expected an error", test.name)
+		}
+	}
+}
+
+func TestAllocateIP(t *testing.T) {
+	tests := []struct {
+		name string
+		pool model.IPPool
+		used []string
+		// want is empty if the pool is expected to be full
+		want string
+	}{
+		{"first address", model.IPPool{CIDR: "10.0.0.0/24", Gateway: "10.0.0.1", RangeStart: "10.0.0.10", RangeEnd: "10.0.0.20"}, nil, "10.0.0.10"},
+		{"skips used addresses", model.IPPool{CIDR: "10.0.0.0/24", Gateway: "10.0.0.1", RangeStart: "10.0.0.10", RangeEnd: "10.0.0.20"}, []string{"10.0.0.10", "10.0.0.11"}, "10.0.0.12"},
+		{"skips the gateway", model.IPPool{CIDR: "10.0.0.0/24", Gateway: "10.0.0.10", RangeStart: "10.0.0.10", RangeEnd: "10.0.0.20"}, nil, "10.0.0.11"},
+		{"/31", model.IPPool{CIDR: "10.0.0.0/31", Gateway: "10.0.0.0", RangeStart: "10.0.0.0", RangeEnd: "10.0.0.1"}, nil, "10.0.0.1"},
+		{"/32 held by the gateway", model.IPPool{CIDR: "10.0.0.5/32", Gateway: "10.0.0.5", RangeStart: "10.0.0.5", RangeEnd: "10.0.0.5"}, nil, ""},
+		{"full pool", model.IPPool{CIDR: "10.0.0.0/24", Gateway: "10.0.0.1", RangeStart: "10.0.0.10", RangeEnd: "10.0.0.11"}, []string{"10.0.0.10", "10.0.0.11"}, ""},
+		{"last address", model.IPPool{CIDR: "255.255.255.254/31", Gateway: "255.255.255.254", RangeStart: "255.255.255.254", RangeEnd: "255.255.255.255"}, nil, "255.255.255.255"},
+		{"full at the last address", model.IPPool{CIDR: "255.255.255.254/31", Gateway: "255.255.255.254", RangeStart: "255.255.255.254", RangeEnd: "255.255.255.255"}, []string{"255.255.255.255"}, ""},
+	}
+	for _, test := range tests {
+		k8snodes := &model.K8sNodes{}
+		for i, ip := range test.used {
+			node := &model.K8sNode{IP: ip, StaticIP: true}
+			if i == 0 {
+				k8snodes.MasterNode = node
+			} else {
+				k8snodes.WorkerNodes = append(k8snodes.WorkerNodes, node)
+			}
+		}
+		ip, err := AllocateIP(&test.pool, k8snodes)
+		if test.want == "" {
+			if err == nil {
+				t.Errorf("%s: allocated %s from a full pool", test.name, ip)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", test.name, err.Error())
+		} else if ip != test.want {
+			t.Errorf("%s: allocated %s, want %s", test.name, ip, test.want)
+		}
+	}
+}
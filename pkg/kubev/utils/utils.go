@@ -15,7 +15,9 @@
 package utils
 
 import (
+	"crypto/rand"
 	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -28,6 +30,7 @@ import (
 	"github.com/jeffwubj/kubev/pkg/kubev/constants"
 	"github.com/jeffwubj/kubev/pkg/kubev/model"
 	"github.com/phayes/permbits"
+	"golang.org/x/crypto/ssh"
 )
 
 // BinaryExists checks whether binary exists with executable permission
@@ -160,6 +163,17 @@ func saveConfigToFile(data []byte, file string) error {
 	return nil
 }
 
+// NewClusterID returns a random ID to tell clusters deployed by kubev apart.
+func NewClusterID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// EncodeToken returns the token other hosts use the cluster with, it only
+// holds the master IP, the SSH key is exported separately.
 func EncodeToken(vmconfig *model.K8sNode) string {
 	token := vmconfig.IP
 	data := []byte(token)
@@ -174,6 +188,19 @@ func DecodeToken(token string) (string, error) {
 	return string(decodeBytes), nil
 }
 
+// SaveSSHKey makes key the SSH key of the nodes and derives its public key,
+// so both always match.
+func SaveSSHKey(key string) error {
+	signer, err := ssh.ParsePrivateKey([]byte(key))
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(constants.GetVMPrivateKeyPath(), []byte(key), 0600); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(constants.GetVMPublicKeyPath(), ssh.MarshalAuthorizedKey(signer.PublicKey()), 0644)
+}
+
 func Is_ipv4(host string) bool {
 	parts := strings.Split(host, ".")
 